@@ -0,0 +1,167 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"null-statement-parser/internal/alias"
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/duplicates"
+
+	"github.com/charmbracelet/x/term"
+)
+
+func runAccounts(args []string) {
+	report.Command = "accounts"
+	if len(args) == 0 {
+		fatalf(exitError, "usage: accounts dedupe")
+	}
+
+	switch args[0] {
+	case "dedupe":
+		runAccountsDedupe(args[1:])
+	default:
+		fatalf(exitError, "unknown accounts command: %s", args[0])
+	}
+}
+
+// runAccountsDedupe finds ariand accounts that look like the same account and
+// merges each group into one, moving the aliases of the others onto it
+func runAccountsDedupe(args []string) {
+	flags := flag.NewFlagSet("accounts dedupe", flag.ExitOnError)
+	minOverlap := flags.Float64("min-overlap", duplicates.DefaultOptions().MinOverlap, "share of transactions two accounts must have in common to count as one")
+	dryRun := flags.Bool("dry-run", false, "list the groups without merging")
+	yes := flags.Bool("yes", false, "merge every group into its suggested account without asking")
+	flags.Parse(args)
+
+	report.Command = "accounts dedupe"
+	userID, serverURL, apiKey := credentials()
+
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		fatalf(classify(err), "client failed: %v", err)
+	}
+	defer nullClient.Close()
+
+	accounts, err := nullClient.GetAccounts(userID)
+	if err != nil {
+		fatalf(classify(err), "get accounts failed: %v", err)
+	}
+
+	candidates := make([]*duplicates.Account, 0, len(accounts))
+	for _, account := range accounts {
+		transactions, err := nullClient.ListTransactions(userID, client.TransactionQuery{AccountID: account.Id})
+		if err != nil {
+			fatalf(classify(err), "list transactions of account %d failed: %v", account.Id, err)
+		}
+		candidates = append(candidates, duplicates.NewAccount(account, transactions))
+	}
+
+	opts := duplicates.DefaultOptions()
+	opts.MinOverlap = *minOverlap
+	groups := duplicates.Find(candidates, opts)
+	if len(groups) == 0 {
+		fmt.Fprintf(out, "no duplicate accounts among %d\n", len(accounts))
+		finish()
+		return
+	}
+
+	interactive := term.IsTerminal(os.Stdin.Fd())
+	for i, group := range groups {
+		fmt.Fprintf(out, "\ngroup %d:\n", i+1)
+		for _, a := range group.Accounts {
+			fmt.Fprintf(out, "  %s\n", duplicates.Describe(a))
+			if len(a.GetAliases()) > 0 {
+				fmt.Fprintf(out, "    aliases: %s\n", strings.Join(a.GetAliases(), ", "))
+			}
+		}
+		for _, reason := range group.Reasons {
+			fmt.Fprintf(out, "  - %s\n", reason)
+		}
+		if *dryRun {
+			continue
+		}
+
+		primaryID := group.Primary().Id
+		switch {
+		case *yes:
+		case interactive:
+			if primaryID, err = duplicates.PromptForPrimary(group); err != nil {
+				fatalf(exitError, "%v", err)
+			}
+		default:
+			fmt.Fprintf(out, "merge into #%d %s? (y/N): ", primaryID, group.Primary().Name)
+			if !readYes() {
+				primaryID = 0
+			}
+		}
+		if primaryID == 0 {
+			fmt.Fprintf(out, "skipped\n")
+			continue
+		}
+
+		mergeGroup(nullClient, userID, group, primaryID)
+	}
+
+	finish()
+}
+
+// mergeGroup merges every other account of the group into the primary, then
+// gives the primary the aliases of all of them, and the number forms of any
+// account that was named after its number
+func mergeGroup(nullClient *client.Client, userID string, group duplicates.Group, primaryID int64) {
+	var primary *duplicates.Account
+	for _, a := range group.Accounts {
+		if a.Id == primaryID {
+			primary = a
+		}
+	}
+
+	aliases := append([]string(nil), primary.GetAliases()...)
+	merged := false
+	for _, a := range group.Accounts {
+		if a.Id == primaryID {
+			continue
+		}
+
+		account, moved, err := nullClient.MergeAccounts(userID, primaryID, a.Id)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			report.Failed = append(report.Failed, rowReport{ID: a.Id, Description: "account " + a.Name, Reason: err.Error()})
+			continue
+		}
+		fmt.Fprintf(out, "merged #%d %s into #%d %s (%d transactions moved)\n", a.Id, a.Name, primary.Id, primary.Name, moved)
+		report.Merged++
+		merged = true
+
+		aliases = append(aliases, account.GetAliases()...)
+		aliases = append(aliases, a.GetAliases()...)
+		if len(alias.Digits(a.Name)) >= 4 {
+			aliases = append(aliases, alias.Variants(a.Name)...)
+		}
+	}
+	if !merged {
+		return
+	}
+
+	seen := make(map[string]bool)
+	unique := aliases[:0]
+	for _, a := range aliases {
+		if key := strings.ToLower(a); !seen[key] {
+			seen[key] = true
+			unique = append(unique, a)
+		}
+	}
+	if len(unique) == len(primary.GetAliases()) {
+		return
+	}
+	if err := nullClient.SetAccountAliases(userID, primaryID, unique); err != nil {
+		log.Printf("ERROR: %v", err)
+		report.Failed = append(report.Failed, rowReport{ID: primaryID, Description: "aliases of " + primary.Name, Reason: err.Error()})
+		return
+	}
+	fmt.Fprintf(out, "#%d %s now has aliases: %s\n", primary.Id, primary.Name, strings.Join(unique, ", "))
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/domain"
+	pb "null-statement-parser/internal/gen/null/v1"
+	"null-statement-parser/internal/journal"
+	"null-statement-parser/internal/normalized"
+)
+
+func runExport(args []string) {
+	flags := flag.NewFlagSet("export", flag.ExitOnError)
+	opts := addParseFlags(flags)
+	format := flags.String("format", "beancount", "beancount or hledger")
+	fromPath := flags.String("from", "", "export a file written by the parse command")
+	remote := flags.Bool("remote", false, "export transactions stored in ariand instead of parsing")
+	startDate := flags.String("start", "", "first date for -remote (YYYY-MM-DD)")
+	endDate := flags.String("end", "", "last date for -remote (YYYY-MM-DD)")
+	accountsPath := flags.String("accounts", settings.Journal, "journal account mapping file (JSON)")
+	outPath := flags.String("o", "", "output file (default: stdout)")
+	flags.Parse(args)
+
+	report.Command = "export"
+	if jsonOutput && (*outPath == "" || *outPath == "-") {
+		fatalf(exitError, "-output json needs -o, stdout is reserved for the result")
+	}
+
+	style, err := journal.ParseStyle(*format)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	cfg, err := journal.LoadConfig(*accountsPath)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	var transactions []*domain.Transaction
+	var statements []*domain.Statement
+
+	switch {
+	case *remote:
+		transactions = fetchRemoteTransactions(*startDate, *endDate)
+		report.Parsed = len(transactions)
+	case *fromPath != "":
+		fromFormat, err := normalized.ResolveFormat("", *fromPath)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		doc, err := normalized.ReadFile(*fromPath, fromFormat)
+		if err != nil {
+			fatalf(exitParse, "read failed: %v", err)
+		}
+		transactions, statements = doc.Transactions, doc.Statements
+		report.Files = append(report.Files, fileReport{File: *fromPath, Transactions: len(transactions), Processed: true})
+		report.Parsed = len(transactions)
+	default:
+		opts.resolve()
+		transactions, statements = parseInputs(os.Stderr, opts)
+	}
+
+	var w io.Writer = os.Stdout
+	if *outPath != "" && *outPath != "-" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		defer file.Close()
+		w = file
+		report.Output = *outPath
+	}
+
+	if err := journal.Write(w, style, cfg, transactions, statements); err != nil {
+		fatalf(exitError, "write failed: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "exported %d transactions, %d statements\n", len(transactions), len(statements))
+	finish()
+}
+
+func fetchRemoteTransactions(startDate, endDate string) []*domain.Transaction {
+	userID, serverURL, apiKey := credentials()
+
+	var query client.TransactionQuery
+	var err error
+	if startDate != "" {
+		if query.Start, err = time.Parse(time.DateOnly, startDate); err != nil {
+			fatalf(exitError, "invalid -start: %v", err)
+		}
+	}
+	if endDate != "" {
+		if query.End, err = time.Parse(time.DateOnly, endDate); err != nil {
+			fatalf(exitError, "invalid -end: %v", err)
+		}
+		query.End = query.End.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		fatalf(classify(err), "client failed: %v", err)
+	}
+	defer nullClient.Close()
+
+	accounts, err := nullClient.GetAccounts(userID)
+	if err != nil {
+		fatalf(classify(err), "get accounts failed: %v", err)
+	}
+	accountsByID := make(map[int64]*pb.Account)
+	for _, a := range accounts {
+		accountsByID[a.Id] = a
+	}
+
+	remoteTxs, err := nullClient.ListTransactions(userID, query)
+	if err != nil {
+		fatalf(classify(err), "%v", err)
+	}
+
+	transactions := make([]*domain.Transaction, 0, len(remoteTxs))
+	for _, rtx := range remoteTxs {
+		transactions = append(transactions, fromRemoteTransaction(rtx, accountsByID[rtx.AccountId]))
+	}
+	return transactions
+}
+
+// fromRemoteTransaction maps an ariand transaction back onto the domain type,
+// using the account name where a statement account number would be. The
+// reference UploadNotes wrote into the notes becomes the ExternalID again.
+func fromRemoteTransaction(rtx *pb.Transaction, account *pb.Account) *domain.Transaction {
+	tx := &domain.Transaction{
+		AccountID:  int(rtx.AccountId),
+		TxDate:     rtx.GetTxDate().AsTime(),
+		TxAmount:   client.MoneyToFloat(rtx.GetTxAmount()),
+		TxCurrency: rtx.GetTxAmount().GetCurrencyCode(),
+		TxDesc:     rtx.GetDescription(),
+		Merchant:   rtx.GetMerchant(),
+		UserNotes:  rtx.GetUserNotes(),
+		EmailID:    rtx.GetEmailId(),
+		ExternalID: domain.Reference(rtx.GetUserNotes()),
+		Category:   rtx.GetCategory().GetSlug(),
+	}
+	if rtx.Direction == pb.TransactionDirection_DIRECTION_INCOMING {
+		tx.TxDirection = domain.In
+	} else {
+		tx.TxDirection = domain.Out
+	}
+
+	if account != nil {
+		name := account.Name
+		tx.StatementAccountNumber = &name
+		tx.StatementAccountName = account.GetFriendlyName()
+		tx.StatementAccountType = accountTypeName(account.Type)
+	}
+
+	return tx
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"null-statement-parser/internal/domain"
+	pb "null-statement-parser/internal/gen/null/v1"
+)
+
+func TestFromRemoteTransactionKeepsReference(t *testing.T) {
+	local := &domain.Transaction{
+		UserNotes:  "disputed",
+		ExternalID: "74500015010000000000123",
+		Source:     domain.SourceCSV,
+	}
+	notes := local.UploadNotes()
+
+	tx := fromRemoteTransaction(&pb.Transaction{UserNotes: &notes}, nil)
+	if tx.ExternalID != local.ExternalID {
+		t.Fatalf("got reference %q from notes %q, want %q", tx.ExternalID, notes, local.ExternalID)
+	}
+
+	plain := "no reference here"
+	if tx := fromRemoteTransaction(&pb.Transaction{UserNotes: &plain}, nil); tx.ExternalID != "" {
+		t.Fatalf("got reference %q from notes without one", tx.ExternalID)
+	}
+}
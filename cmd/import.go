@@ -0,0 +1,1052 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/alias"
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/domain"
+	"null-statement-parser/internal/email"
+	pb "null-statement-parser/internal/gen/null/v1"
+	"null-statement-parser/internal/mapping"
+	"null-statement-parser/internal/normalized"
+	"null-statement-parser/internal/parser"
+	"null-statement-parser/internal/reconcile"
+	"null-statement-parser/internal/review"
+	"null-statement-parser/internal/session"
+
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/x/term"
+)
+
+type parseOptions struct {
+	pdfPath    string
+	csvPath    string
+	filePaths  []string
+	emailPaths []string
+	configPath string
+	workers    int
+
+	csvProfile     string
+	csvAccount     string
+	csvAccountType string
+
+	mt940AccountType string
+}
+
+// addParseFlags registers the input flags, defaulting to the active profile
+func addParseFlags(flags *flag.FlagSet) *parseOptions {
+	opts := &parseOptions{}
+	flags.StringVar(&opts.pdfPath, "pdf", settings.PDFPath, "")
+	flags.StringVar(&opts.csvPath, "csv", settings.CSVPath, "")
+	flags.StringVar(&opts.configPath, "config", settings.ParserConfig, "")
+	flags.Func("file", "QIF, CAMT or MT940 statement file or folder (repeatable)", func(path string) error {
+		opts.filePaths = append(opts.filePaths, path)
+		return nil
+	})
+	flags.Func("email", "alert email .eml file, mbox or Maildir (repeatable)", func(path string) error {
+		opts.emailPaths = append(opts.emailPaths, path)
+		return nil
+	})
+	flags.StringVar(&opts.csvProfile, "csv-profile", settings.CSVProfile, "CSV layout: "+strings.Join(parser.CSVProfileNames(), ", ")+" or a profile file (default: detect)")
+	flags.StringVar(&opts.csvAccount, "csv-account", "", "account number for CSV exports that don't include one")
+	flags.StringVar(&opts.csvAccountType, "csv-account-type", "", "account type for CSV exports that don't include one")
+	flags.StringVar(&opts.mt940AccountType, "mt940-account-type", settings.MT940AccountType, "account type for MT940 statements (default: chequing)")
+	flags.IntVar(&opts.workers, "workers", settings.Workers, "PDFs parsed in parallel (default: number of CPUs, at most 4)")
+	return opts
+}
+
+// resolve exits if there is nothing to parse
+func (opts *parseOptions) resolve() {
+	if opts.pdfPath == "" && opts.csvPath == "" && len(opts.filePaths) == 0 && len(opts.emailPaths) == 0 {
+		fatalf(exitError, "need -pdf, -csv, -file or -email flag")
+	}
+}
+
+// parseInputs runs the PDF parser and merges the CSV export on top, writing
+// progress to w
+func parseInputs(w io.Writer, opts *parseOptions) ([]*domain.Transaction, []*domain.Statement) {
+	var parseResult *parser.ParseResult
+	var transactions []*domain.Transaction
+	var statements []*domain.Statement
+
+	if opts.pdfPath != "" {
+		pythonParser := parser.NewPythonParser()
+		if opts.workers > 0 {
+			pythonParser.Workers = opts.workers
+		}
+		pythonParser.Passwords = settings.PDFPasswordsFor
+		if term.IsTerminal(os.Stdin.Fd()) {
+			pythonParser.Prompt = promptPDFPassword
+		}
+
+		fmt.Fprintf(w, "parsing %s\n", opts.pdfPath)
+		var err error
+		parseResult, transactions, err = pythonParser.ParseStatements(opts.pdfPath, opts.configPath)
+		if err != nil {
+			fatalf(exitParse, "parse failed: %v", err)
+		}
+
+		fmt.Fprintf(w, "files: %d/%d, transactions: %d\n",
+			parseResult.Summary.ProcessedFiles,
+			parseResult.Summary.TotalFiles,
+			parseResult.Summary.TotalTransactions)
+
+		for _, fileResult := range parseResult.FileResults {
+			if fileResult.Error != "" {
+				log.Printf("ERROR: %s: %s", filepath.Base(fileResult.File), fileResult.Error)
+			} else if fileResult.Processed {
+				fmt.Fprintf(w, "  %s: %d\n", filepath.Base(fileResult.File), fileResult.TransactionCount)
+			}
+			if fileResult.StatementError != "" {
+				log.Printf("WARN: %s: no statement balances: %s", filepath.Base(fileResult.File), fileResult.StatementError)
+			}
+			report.Files = append(report.Files, fileReport{
+				File:         fileResult.File,
+				Transactions: fileResult.TransactionCount,
+				Processed:    fileResult.Processed,
+				Error:        fileResult.Error,
+			})
+		}
+		statements = parseResult.Statements()
+	}
+
+	for _, path := range opts.filePaths {
+		files, err := parser.FindStatementFiles(path)
+		if err != nil {
+			fatalf(exitParse, "%v", err)
+		}
+
+		fmt.Fprintf(w, "\nparsing %s\n", path)
+		for _, file := range files {
+			fileTransactions, fileStatements, err := parser.ParseFile(file, opts.mt940AccountType)
+			if err != nil {
+				log.Printf("ERROR: %v", err)
+				report.Files = append(report.Files, fileReport{File: file, Error: err.Error()})
+				continue
+			}
+
+			fmt.Fprintf(w, "  %s: %d\n", filepath.Base(file), len(fileTransactions))
+			report.Files = append(report.Files, fileReport{File: file, Transactions: len(fileTransactions), Processed: true})
+			transactions = append(transactions, fileTransactions...)
+			statements = append(statements, fileStatements...)
+		}
+	}
+
+	if opts.csvPath != "" {
+		csvParser := parser.NewCSVParser()
+		csvParser.AccountNumber = opts.csvAccount
+		csvParser.AccountType = opts.csvAccountType
+		fmt.Fprintf(w, "\nparsing CSV %s\n", opts.csvPath)
+
+		var err error
+		if opts.csvProfile != "" {
+			csvParser.Profile, err = parser.LoadCSVProfile(opts.csvProfile)
+		} else {
+			csvParser.Profile, err = csvParser.DetectCSVProfile(opts.csvPath)
+		}
+		if err != nil {
+			fatalf(exitParse, "CSV parse failed: %v", err)
+		}
+		fmt.Fprintf(w, "CSV profile: %s\n", csvParser.Profile.Name)
+
+		csvTransactions, err := csvParser.ParseCSV(opts.csvPath)
+		if err != nil {
+			fatalf(exitParse, "CSV parse failed: %v", err)
+		}
+
+		fmt.Fprintf(w, "CSV transactions: %d\n", len(csvTransactions))
+		report.Files = append(report.Files, fileReport{File: opts.csvPath, Transactions: len(csvTransactions), Processed: true})
+
+		originalCount := len(transactions)
+		var merges []parser.AccountMerge
+		transactions, merges = parser.MergeCSVWithStatements(transactions, csvTransactions)
+		fmt.Fprintf(w, "merged: %d new from CSV\n", len(transactions)-originalCount)
+		reportMerges(w, "CSV", merges)
+	}
+
+	var emailTransactions []*domain.Transaction
+	for _, path := range opts.emailPaths {
+		fmt.Fprintf(w, "\nreading alert emails %s\n", path)
+		alerts, skipped, errs, err := email.ParsePath(path)
+		if err != nil {
+			fatalf(exitParse, "%v", err)
+		}
+		for _, err := range errs {
+			log.Printf("ERROR: %v", err)
+			report.Files = append(report.Files, fileReport{File: path, Error: err.Error()})
+		}
+
+		fmt.Fprintf(w, "alerts: %d, other messages: %d\n", len(alerts), skipped)
+		report.Files = append(report.Files, fileReport{File: path, Transactions: len(alerts), Processed: true})
+		emailTransactions = append(emailTransactions, alerts...)
+	}
+	if len(emailTransactions) > 0 {
+		// alerts are superseded by statements and CSV rows, like CSV rows are
+		// by statements
+		originalCount := len(transactions)
+		var merges []parser.AccountMerge
+		transactions, merges = parser.MergeCSVWithStatements(transactions, emailTransactions)
+		fmt.Fprintf(w, "merged: %d new from email\n", len(transactions)-originalCount)
+		reportMerges(w, "email", merges)
+	}
+
+	var dropped int
+	transactions, dropped = parser.Deduplicate(transactions)
+	if dropped > 0 {
+		fmt.Fprintf(w, "dropped %d duplicate lines by bank reference or alert email\n", dropped)
+	}
+
+	report.Parsed = len(transactions)
+	return transactions, statements
+}
+
+// reportMerges prints what the merge did per account, and warns about rows
+// that could belong to more than one statement account
+func reportMerges(w io.Writer, source string, merges []parser.AccountMerge) {
+	for _, m := range merges {
+		report.Merges = append(report.Merges, mergeReport{Source: source, AccountMerge: m})
+
+		account := m.Account
+		if account == "" {
+			account = "no account number"
+		} else if m.Type != "" {
+			account += " (" + m.Type + ")"
+		}
+		switch {
+		case m.Ambiguous():
+			fmt.Fprintf(w, "  %s: kept %d, matches %s\n", account, m.Kept, strings.Join(m.Candidates, ", "))
+			log.Printf("WARN: %s account %s ends like statement accounts %s; its %d rows are kept under their own number, map it to pick one", source, account, strings.Join(m.Candidates, ", "), m.Kept)
+		case m.Matched != "":
+			fmt.Fprintf(w, "  %s -> %s: after %s, kept %d, dropped %d\n", account, m.Matched, m.Cutoff.Format(time.DateOnly), m.Kept, m.Dropped)
+		case m.Account == "":
+			fmt.Fprintf(w, "  %s: dropped %d\n", account, m.Dropped)
+		default:
+			fmt.Fprintf(w, "  %s: no statement, kept %d\n", account, m.Kept)
+		}
+	}
+}
+
+// promptPDFPassword asks for the password of an encrypted statement, showing
+// the profile's hints for it
+func promptPDFPassword(file string, hints []string) (string, error) {
+	var password string
+	description := "leave empty to skip this file"
+	if len(hints) > 0 {
+		description = "hint: " + strings.Join(hints, "; ") + "\n" + description
+	}
+
+	err := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(fmt.Sprintf("Password for %s", filepath.Base(file))).
+				Description(description).
+				EchoMode(huh.EchoModePassword).
+				Value(&password),
+		),
+	).WithOutput(os.Stderr).Run()
+	if err != nil {
+		return "", fmt.Errorf("password prompt failed: %w", err)
+	}
+	return password, nil
+}
+
+func runImport(args []string) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "undo":
+			runImportUndo(args[1:])
+			return
+		case "sessions":
+			runImportSessions(args[1:])
+			return
+		}
+	}
+
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	opts := addParseFlags(flags)
+	fromPath := flags.String("from", "", "upload a file written by the parse command instead of parsing")
+	fromFormat := flags.String("format", "", "format of the -from file: json, ndjson or csv (default: by extension)")
+	dateSource := flags.String("date-source", string(domain.TransactionDate), "date sent to ariand: transaction or posting")
+	reviewTable := flags.Bool("review", true, "review transactions in a table before upload (terminal only)")
+	reconcileCSV := flags.Bool("reconcile", true, "update rows previously imported from CSV with matching statement lines instead of creating new ones")
+	reconcileUntagged := flags.Bool("reconcile-untagged", false, "also reconcile rows without a source tag whose description is alike")
+	mappingPath := flags.String("mapping", settings.AccountMapping, "account mapping file (JSON) used before prompting")
+	batchSize := flags.Int("batch-size", settings.BatchSize, "transactions per upload request")
+	yes := flags.Bool("yes", false, "upload without the review table or confirmation prompt")
+	flags.Parse(args)
+
+	report.Command = "import"
+	if *fromPath == "" {
+		opts.resolve()
+	}
+	source, err := domain.ParseDateSource(*dateSource)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	if *batchSize <= 0 {
+		fatalf(exitError, "-batch-size must be positive")
+	}
+	accountMapping, err := mapping.LoadFile(*mappingPath)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	userID, serverURL, apiKey := credentials()
+
+	var transactions []*domain.Transaction
+	var statements []*domain.Statement
+	if *fromPath != "" {
+		format, err := normalized.ResolveFormat(*fromFormat, *fromPath)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		doc, err := normalized.ReadFile(*fromPath, format)
+		if err != nil {
+			fatalf(exitParse, "read failed: %v", err)
+		}
+		transactions, statements = doc.Transactions, doc.Statements
+		fmt.Fprintf(out, "loaded %d transactions from %s\n", len(transactions), *fromPath)
+		report.Files = append(report.Files, fileReport{File: *fromPath, Transactions: len(transactions), Processed: true})
+		report.Parsed = len(transactions)
+	} else {
+		transactions, statements = parseInputs(out, opts)
+	}
+
+	if len(transactions) == 0 && !hasAccountValues(statements) {
+		finish()
+		return
+	}
+
+	uploadOpts := uploadOptions{
+		dateSource:     source,
+		reconcile:      *reconcileCSV,
+		untagged:       *reconcileUntagged,
+		accountMapping: accountMapping,
+		batchSize:      *batchSize,
+		prompt:         true,
+	}
+
+	switch {
+	case *yes, len(transactions) == 0:
+	case *reviewTable && term.IsTerminal(os.Stdin.Fd()):
+		var warnings map[*domain.Transaction][]string
+		if uploadOpts.reconcile {
+			warnings = reconcileWarnings(userID, serverURL, apiKey, uploadOpts, transactions, statements)
+		}
+		selected, confirmed, err := review.Run(transactions, warnings)
+		if err != nil {
+			fatalf(exitError, "%v", err)
+		}
+		if !confirmed || len(selected) == 0 {
+			fmt.Fprintf(out, "nothing uploaded\n")
+			finish()
+			return
+		}
+		chosen := make(map[*domain.Transaction]bool, len(selected))
+		for _, tx := range selected {
+			chosen[tx] = true
+		}
+		for _, tx := range transactions {
+			if !chosen[tx] {
+				report.Skipped = append(report.Skipped, txRow(tx, "deselected in review"))
+			}
+		}
+		transactions = selected
+	case !confirmUpload(len(transactions)):
+		finish()
+		return
+	}
+
+	err = uploadTransactions(userID, serverURL, apiKey, uploadOpts, transactions, statements)
+	if err != nil {
+		fatalf(classify(err), "%v", err)
+	}
+	finish()
+}
+
+// hasAccountValues reports whether any statement carries an account value
+// snapshot, which is worth uploading even without transactions
+func hasAccountValues(statements []*domain.Statement) bool {
+	for _, s := range statements {
+		if s.AccountValue != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func confirmUpload(count int) bool {
+	fmt.Fprintf(out, "\nupload %d transactions? (y/N): ", count)
+	return readYes()
+}
+
+// readYes reads a y/N answer from stdin
+func readYes() bool {
+	reader := bufio.NewReader(os.Stdin)
+	response, err := reader.ReadString('\n')
+	if err != nil {
+		fatalf(exitError, "read failed: %v", err)
+	}
+
+	response = strings.TrimSpace(strings.ToLower(response))
+	return response == "y" || response == "yes"
+}
+
+type uploadOptions struct {
+	dateSource domain.DateSource
+	reconcile  bool
+	// untagged lets reconciliation match rows without a source tag
+	untagged       bool
+	accountMapping *mapping.File
+	batchSize      int
+	// prompt asks how to map unknown accounts. Without it their rows fail,
+	// unless the mapping file plans an account for them.
+	prompt bool
+}
+
+// reconcileOptions returns the reconciliation settings for this upload
+func (opts uploadOptions) reconcileOptions() reconcile.Options {
+	reconcileOpts := reconcile.DefaultOptions()
+	reconcileOpts.MatchUntagged = opts.untagged
+	return reconcileOpts
+}
+
+// uploadTransactions resolves the ariand account of every statement account
+// and uploads the transactions in batches. Rows that fail are added to
+// report.Failed; an error means the upload stopped.
+func uploadTransactions(userID, serverURL, apiKey string, opts uploadOptions, transactions []*domain.Transaction, statements []*domain.Statement) error {
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		return fmt.Errorf("client failed: %w", err)
+	}
+	defer nullClient.Close()
+	nullClient.SetDateSource(opts.dateSource)
+
+	_, err = nullClient.GetUser(userID)
+	if err != nil {
+		return fmt.Errorf("user not found: %w", err)
+	}
+
+	accounts, err := nullClient.GetAccounts(userID)
+	if err != nil {
+		return fmt.Errorf("get accounts failed: %w", err)
+	}
+
+	sess := session.New(sourceFiles(transactions))
+	// an import that changed nothing leaves no session behind to undo
+	saveSession := func() {
+		if sess.IsEmpty() {
+			return
+		}
+		report.SessionID = sess.ID
+		if err := sess.Save(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}
+
+	// both are keyed by parser.AccountKey, so a card and a bank account with
+	// the same number resolve apart
+	resolvedAccounts := make(map[string]*pb.Account)
+	accountMatchStats := make(map[string]int)
+
+	for _, ref := range statementAccounts(transactions, statements) {
+		accountName := ref.number
+
+		matchedAccount, err := findAccountByAliases(nullClient, userID, ref)
+		if err != nil {
+			return fmt.Errorf("alias lookup failed: %w", err)
+		}
+
+		if matchedAccount == nil {
+			matchedAccount, err = opts.accountMapping.Lookup(accountName, accounts)
+			if err != nil {
+				return err
+			}
+			if matchedAccount != nil {
+				warnTypeMismatch(accountName, ref.accountType, matchedAccount)
+			}
+		}
+
+		if matchedAccount == nil {
+			spec, planned := opts.accountMapping.NewAccountFor(accountName)
+			if !planned && !opts.prompt {
+				log.Printf("ERROR: no ariand account for '%s', add it to the account mapping file", accountName)
+				continue
+			}
+			isNewAccount := planned
+			selectedAccountID := ""
+			if !planned {
+				selectedAccountID, isNewAccount, err = mapping.PromptForAccountMapping(accountName, accounts)
+				if err != nil {
+					return fmt.Errorf("mapping prompt failed: %w", err)
+				}
+			}
+
+			if isNewAccount {
+				defaults := newAccountDefaults(ref, statements, accounts)
+				if planned {
+					spec = spec.WithDefaults(defaults)
+					spec.Currency = strings.ToUpper(spec.Currency)
+				} else if spec, err = mapping.PromptNewAccount(accountName, defaults); err != nil {
+					return fmt.Errorf("new account prompt failed: %w", err)
+				}
+				if err := spec.Validate(); err != nil {
+					return fmt.Errorf("account '%s': %w", accountName, err)
+				}
+
+				newAccount, err := createAccount(nullClient, userID, spec)
+				if err != nil {
+					freshAccounts, ferr := nullClient.GetAccounts(userID)
+					if ferr != nil {
+						return fmt.Errorf("create account failed: %w (also failed to refresh accounts: %v)", err, ferr)
+					}
+					accounts = freshAccounts
+					for _, a := range freshAccounts {
+						if strings.EqualFold(a.Name, spec.Name) {
+							newAccount = a
+							break
+						}
+					}
+					if newAccount == nil {
+						return fmt.Errorf("create account failed: %w", err)
+					}
+					log.Printf("account '%s' already existed (id=%d), using it", spec.Name, newAccount.Id)
+				} else {
+					fmt.Fprintf(out, "created account '%s' (id=%d) for %s\n", spec.Name, newAccount.Id, accountName)
+					accounts = append(accounts, newAccount)
+					sess.AddCreatedAccount(newAccount.Id)
+					saveSession()
+				}
+				matchedAccount = newAccount
+			} else {
+				selectedAccountIDInt, _ := strconv.ParseInt(selectedAccountID, 10, 64)
+				for _, account := range accounts {
+					if account.Id == selectedAccountIDInt {
+						matchedAccount = account
+						break
+					}
+				}
+				if matchedAccount == nil {
+					return fmt.Errorf("selected account not found")
+				}
+				warnTypeMismatch(accountName, ref.accountType, matchedAccount)
+			}
+		}
+
+		warnCurrencyMismatch(accountName, ref.currency, matchedAccount)
+
+		if added := registerAliases(nullClient, userID, matchedAccount, accounts, accountName); len(added) > 0 {
+			for _, a := range added {
+				sess.AddAlias(matchedAccount.Id, a)
+			}
+			saveSession()
+		}
+
+		resolvedAccounts[parser.AccountKey(accountName, ref.accountType)] = matchedAccount
+	}
+
+	resolved := transactions[:0:0]
+	for _, tx := range transactions {
+		accountName := "Unknown"
+		if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+			accountName = *tx.StatementAccountNumber
+		}
+
+		key := parser.AccountKey(accountName, tx.StatementAccountType)
+		matchedAccount := resolvedAccounts[key]
+		if matchedAccount == nil {
+			report.Failed = append(report.Failed, txRow(tx, "no ariand account for '"+accountName+"'"))
+			continue
+		}
+		tx.AccountID = int(matchedAccount.Id)
+		accountMatchStats[accountName]++
+		resolved = append(resolved, tx)
+	}
+	transactions = resolved
+
+	updateAnchors(nullClient, userID, statements, resolvedAccounts)
+	transactions = dropImportedEmails(nullClient, userID, transactions)
+
+	if opts.reconcile {
+		transactions = reconcileProvisional(nullClient, userID, sess, opts.reconcileOptions(), transactions, statements)
+		saveSession()
+	}
+
+	batchSize := opts.batchSize
+	totalCreated := int32(0)
+	totalErrors := 0
+
+	for i := 0; i < len(transactions); i += batchSize {
+		end := i + batchSize
+		if end > len(transactions) {
+			end = len(transactions)
+		}
+
+		created, ids, errors := nullClient.CreateTransactionsBulk(userID, transactions[i:end])
+		totalCreated += created
+		totalErrors += len(errors)
+		sess.AddTransactions(ids...)
+		saveSession()
+
+		for _, err := range errors {
+			if classify(err) == exitAuth {
+				return fmt.Errorf("upload failed: %w", err)
+			}
+			log.Printf("ERROR: %v", err)
+		}
+		// the bulk endpoint is all-or-nothing, so a batch error covers every row
+		if len(errors) > 0 {
+			for _, tx := range transactions[i:end] {
+				report.Failed = append(report.Failed, txRow(tx, errors[0].Error()))
+			}
+		} else if created == 0 {
+			for _, tx := range transactions[i:end] {
+				report.Skipped = append(report.Skipped, txRow(tx, "already exists"))
+			}
+		}
+
+		fmt.Fprintf(out, "%d/%d\n", end, len(transactions))
+	}
+
+	fmt.Fprintf(out, "\n%d ok, %d failed\n", totalCreated, totalErrors)
+	report.Created = int(totalCreated)
+	report.Accounts = accountMatchStats
+	for account, count := range accountMatchStats {
+		fmt.Fprintf(out, "  %s: %d\n", account, count)
+	}
+	if !sess.IsEmpty() {
+		fmt.Fprintf(out, "session: %s (undo with `import undo %s`)\n", sess.ID, sess.ID)
+	}
+	return nil
+}
+
+type accountRef struct {
+	number      string
+	accountType string
+	// currency, name and bank are those of the first transaction or
+	// statement seen
+	currency string
+	name     string
+	bank     string
+}
+
+// statementAccounts lists the distinct accounts that need an ariand account:
+// those with transactions, and those with an account value snapshot
+func statementAccounts(transactions []*domain.Transaction, statements []*domain.Statement) []accountRef {
+	seen := make(map[string]bool)
+	var refs []accountRef
+	add := func(ref accountRef) {
+		key := parser.AccountKey(ref.number, ref.accountType)
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		if ref.currency == "" {
+			ref.currency = domain.DefaultCurrency
+		}
+		refs = append(refs, ref)
+	}
+
+	for _, tx := range transactions {
+		accountName := "Unknown"
+		if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+			accountName = *tx.StatementAccountNumber
+		}
+		add(accountRef{accountName, tx.StatementAccountType, tx.TxCurrency, tx.StatementAccountName, tx.StatementBank})
+	}
+	for _, s := range statements {
+		if s.AccountValue != nil && s.AccountNumber != "" {
+			add(accountRef{s.AccountNumber, s.AccountType, s.Currency, s.AccountName, ""})
+		}
+	}
+	return refs
+}
+
+// findAccountByAliases looks the statement account up under every form of its
+// number, most specific first. An account found only by a masked form or the
+// last 4 digits must also have the statement's type, since a card and a bank
+// account can end in the same digits.
+func findAccountByAliases(nullClient *client.Client, userID string, ref accountRef) (*pb.Account, error) {
+	statementType := convertToAccountType(ref.accountType)
+	for _, variant := range alias.Variants(ref.number) {
+		account, err := nullClient.FindAccountByAlias(userID, variant)
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			continue
+		}
+		if alias.IsPartial(variant, ref.number) && statementType != pb.AccountType_ACCOUNT_UNSPECIFIED && account.Type != statementType {
+			log.Printf("WARN: account '%s' ends like '%s' but is %s, not %s; not using it", ref.number, account.Name, account.Type, statementType)
+			continue
+		}
+		return account, nil
+	}
+	return nil, nil
+}
+
+// registerAliases adds every form of the statement account number the account
+// doesn't have yet as an alias, in one call, and returns the ones added
+func registerAliases(nullClient *client.Client, userID string, account *pb.Account, accounts []*pb.Account, accountName string) []string {
+	existing := slices.Clone(account.GetAliases())
+	// an alias another account holds, like the last 4 digits of a card ending
+	// the same way, stays with that account
+	taken := make(map[string]bool)
+	for _, a := range accounts {
+		if a.Id == account.Id {
+			existing = append(existing, a.GetAliases()...)
+			continue
+		}
+		for _, other := range a.GetAliases() {
+			taken[strings.ToLower(other)] = true
+		}
+	}
+
+	have := make(map[string]bool, len(existing))
+	var aliases []string
+	for _, a := range existing {
+		if key := strings.ToLower(a); !have[key] {
+			have[key] = true
+			aliases = append(aliases, a)
+		}
+	}
+
+	var added []string
+	for _, variant := range alias.Variants(accountName) {
+		key := strings.ToLower(variant)
+		if !have[key] && !taken[key] {
+			have[key] = true
+			added = append(added, variant)
+		}
+	}
+	if len(added) == 0 {
+		return nil
+	}
+
+	aliases = append(aliases, added...)
+	if err := nullClient.SetAccountAliases(userID, account.Id, aliases); err != nil {
+		log.Printf("WARN: failed to add aliases: %v", err)
+		return nil
+	}
+	account.Aliases = aliases
+	return added
+}
+
+// newAccountDefaults suggests the account to create for a statement account:
+// the statement's account name, bank, type and currency, anchored at the
+// opening balance of its earliest statement. Names already taken, say by a
+// second Visa card, get the last 4 digits appended.
+func newAccountDefaults(ref accountRef, statements []*domain.Statement, accounts []*pb.Account) mapping.NewAccount {
+	defaults := mapping.NewAccount{
+		Name:     ref.name,
+		Bank:     ref.bank,
+		Type:     accountTypeName(convertToAccountType(ref.accountType)),
+		Currency: ref.currency,
+	}
+	if defaults.Name == "" {
+		defaults.Name = ref.number
+	} else if slices.ContainsFunc(accounts, func(a *pb.Account) bool { return strings.EqualFold(a.Name, defaults.Name) }) {
+		defaults.Name += " " + alias.Last4(ref.number)
+	}
+
+	var earliest *domain.Statement
+	for _, s := range statements {
+		if s.AccountNumber != ref.number || s.OpeningBalance == nil || s.PeriodStart.IsZero() {
+			continue
+		}
+		if earliest == nil || s.PeriodStart.Before(earliest.PeriodStart) {
+			earliest = s
+		}
+	}
+	if earliest != nil {
+		// credit card statements show the amount owed, which is a negative
+		// balance in ariand
+		balance := *earliest.OpeningBalance
+		if defaults.Type == "credit_card" {
+			balance = -balance
+		}
+		defaults.AnchorBalance = &balance
+		defaults.AnchorDate = earliest.PeriodStart.Format(time.DateOnly)
+	}
+	return defaults
+}
+
+// createAccount creates an ariand account and sets its anchor date when one
+// was given. Failing to set the date only logs a warning.
+func createAccount(nullClient *client.Client, userID string, spec mapping.NewAccount) (*pb.Account, error) {
+	account := client.NewAccount{
+		Name:         spec.Name,
+		FriendlyName: spec.FriendlyName,
+		Bank:         spec.Bank,
+		Type:         convertToAccountType(spec.Type),
+		Currency:     spec.Currency,
+	}
+	if spec.Color != "" {
+		account.Colors = []string{spec.Color}
+	}
+	if spec.AnchorBalance != nil {
+		account.AnchorBalance = *spec.AnchorBalance
+	}
+
+	created, err := nullClient.CreateAccount(userID, account)
+	if err != nil {
+		return nil, err
+	}
+
+	anchorDate, _ := spec.ParsedAnchorDate()
+	if spec.AnchorBalance != nil && !anchorDate.IsZero() {
+		if err := nullClient.SetAnchor(userID, created.Id, *spec.AnchorBalance, spec.Currency, anchorDate); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}
+	return created, nil
+}
+
+// updateAnchors moves each investment account's anchor to the account value
+// of its latest statement, so the balance in ariand follows the market value
+// and not only the cash moved in and out. Anchors newer than the statement
+// are left alone.
+func updateAnchors(nullClient *client.Client, userID string, statements []*domain.Statement, resolvedAccounts map[string]*pb.Account) {
+	latest := make(map[*pb.Account]*domain.Statement)
+	for _, s := range statements {
+		account := resolvedAccounts[parser.AccountKey(s.AccountNumber, s.AccountType)]
+		if s.AccountValue == nil || s.PeriodEnd.IsZero() || account == nil {
+			continue
+		}
+		if prev := latest[account]; prev == nil || s.PeriodEnd.After(prev.PeriodEnd) {
+			latest[account] = s
+		}
+	}
+
+	for account, s := range latest {
+		if account.AnchorDate != nil && account.AnchorDate.AsTime().After(s.PeriodEnd) {
+			continue
+		}
+		currency := account.MainCurrency
+		if currency == "" {
+			currency = domain.DefaultCurrency
+		}
+		if err := nullClient.SetAnchor(userID, account.Id, *s.AccountValue, currency, s.PeriodEnd); err != nil {
+			log.Printf("WARN: %v", err)
+			continue
+		}
+		fmt.Fprintf(out, "anchored %s at %.2f on %s\n", s.AccountNumber, *s.AccountValue, s.PeriodEnd.Format(time.DateOnly))
+		report.Anchored++
+	}
+}
+
+// warnCurrencyMismatch flags accounts whose currency differs from the
+// statement's, since ariand would then add up amounts in two currencies
+func warnCurrencyMismatch(accountName, currency string, account *pb.Account) {
+	if account.MainCurrency != "" && !strings.EqualFold(account.MainCurrency, currency) {
+		log.Printf("WARN: account '%s' currency mismatch - statement is in %s but account is %s (continuing anyway)", accountName, currency, account.MainCurrency)
+	}
+}
+
+func warnTypeMismatch(accountName, statementType string, account *pb.Account) {
+	expectedType := convertToAccountType(statementType)
+	if account.Type != expectedType {
+		log.Printf("WARN: account '%s' type mismatch - statement expects %s but account is %s (continuing anyway)", accountName, expectedType, account.Type)
+	}
+}
+
+// sourceFiles lists the distinct input files transactions were parsed from
+func sourceFiles(transactions []*domain.Transaction) []string {
+	seen := make(map[string]bool)
+	var files []string
+	for _, tx := range transactions {
+		if tx.SourceFilePath != "" && !seen[tx.SourceFilePath] {
+			seen[tx.SourceFilePath] = true
+			files = append(files, tx.SourceFilePath)
+		}
+	}
+	return files
+}
+
+// dropImportedEmails removes alert transactions whose email was imported
+// before, found by the Message-ID kept in the uploaded notes. The row may
+// since have been replaced by its statement line, which keeps the notes.
+func dropImportedEmails(nullClient *client.Client, userID string, transactions []*domain.Transaction) []*domain.Transaction {
+	window := time.Duration(reconcile.DefaultOptions().WindowDays) * 24 * time.Hour
+
+	periods := make(map[int]reconcile.Period)
+	for _, tx := range transactions {
+		if tx.EmailID == "" {
+			continue
+		}
+		p, ok := periods[tx.AccountID]
+		if !ok || tx.TxDate.Before(p.Start) {
+			p.Start = tx.TxDate
+		}
+		if !ok || tx.TxDate.After(p.End) {
+			p.End = tx.TxDate
+		}
+		periods[tx.AccountID] = p
+	}
+	if len(periods) == 0 {
+		return transactions
+	}
+
+	imported := make(map[string]bool)
+	for accountID, period := range periods {
+		existing, err := nullClient.ListTransactions(userID, client.TransactionQuery{
+			AccountID: int64(accountID),
+			Start:     period.Start.Add(-window),
+			End:       period.End.Add(window + 24*time.Hour),
+		})
+		if err != nil {
+			log.Printf("WARN: can't check account %d for imported alerts: %v", accountID, err)
+			continue
+		}
+		for _, e := range existing {
+			for _, id := range domain.EmailIDs(e.GetUserNotes()) {
+				imported[id] = true
+			}
+		}
+	}
+
+	remaining := make([]*domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if tx.EmailID != "" && imported[tx.EmailID] {
+			report.Skipped = append(report.Skipped, txRow(tx, "alert email already imported"))
+			continue
+		}
+		remaining = append(remaining, tx)
+	}
+	if dropped := len(transactions) - len(remaining); dropped > 0 {
+		fmt.Fprintf(out, "skipped %d alert emails imported before\n", dropped)
+	}
+	return remaining
+}
+
+// reconcileProvisional updates rows previously uploaded from a CSV export with
+// the statement lines that supersede them, and returns the transactions that
+// still need to be created
+func reconcileProvisional(nullClient *client.Client, userID string, sess *session.Session, opts reconcile.Options, transactions []*domain.Transaction, statements []*domain.Statement) []*domain.Transaction {
+	matches := reconcile.Find(transactions, existingRows(nullClient, userID, transactions, statements, opts), opts)
+	if len(matches) == 0 {
+		return transactions
+	}
+
+	reconciled := make(map[*domain.Transaction]bool)
+	for _, m := range matches {
+		update := *m.Statement
+		update.UserNotes = reconcile.MergedNotes(m)
+		if err := nullClient.UpdateTransaction(userID, m.Existing.Id, &update); err != nil {
+			log.Printf("WARN: %v, creating it instead", err)
+			continue
+		}
+		reconciled[m.Statement] = true
+		sess.AddReconciled(m.Existing.Id)
+	}
+
+	remaining := make([]*domain.Transaction, 0, len(transactions)-len(reconciled))
+	for _, tx := range transactions {
+		if !reconciled[tx] {
+			remaining = append(remaining, tx)
+		}
+	}
+
+	fmt.Fprintf(out, "reconciled %d statement lines with rows imported from CSV\n", len(reconciled))
+	report.Reconciled = len(reconciled)
+	return remaining
+}
+
+// existingRows lists the ariand rows of each account over the statement
+// periods, widened by the reconciliation window
+func existingRows(nullClient *client.Client, userID string, transactions []*domain.Transaction, statements []*domain.Statement, opts reconcile.Options) []*pb.Transaction {
+	window := time.Duration(opts.WindowDays) * 24 * time.Hour
+
+	var existing []*pb.Transaction
+	for accountID, period := range reconcile.Periods(transactions, statements) {
+		if accountID == 0 {
+			continue
+		}
+		txs, err := nullClient.ListTransactions(userID, client.TransactionQuery{
+			AccountID: int64(accountID),
+			Start:     period.Start.Add(-window),
+			End:       period.End.Add(window + 24*time.Hour),
+		})
+		if err != nil {
+			log.Printf("WARN: skipping reconciliation for account %d: %v", accountID, err)
+			continue
+		}
+		existing = append(existing, txs...)
+	}
+	return existing
+}
+
+// reconcileWarnings previews reconciliation for the review table: the
+// statement lines that will update a row already in ariand instead of being
+// created. Only accounts known by alias or the mapping file are looked at,
+// nothing is prompted for or changed.
+func reconcileWarnings(userID, serverURL, apiKey string, opts uploadOptions, transactions []*domain.Transaction, statements []*domain.Statement) map[*domain.Transaction][]string {
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		log.Printf("WARN: no reconciliation preview: %v", err)
+		return nil
+	}
+	defer nullClient.Close()
+
+	accounts, err := nullClient.GetAccounts(userID)
+	if err != nil {
+		log.Printf("WARN: no reconciliation preview: %v", err)
+		return nil
+	}
+
+	resolved := make(map[string]int64)
+	for _, ref := range statementAccounts(transactions, statements) {
+		account, err := findAccountByAliases(nullClient, userID, ref)
+		if err != nil {
+			log.Printf("WARN: no reconciliation preview: %v", err)
+			return nil
+		}
+		if account == nil {
+			if account, err = opts.accountMapping.Lookup(ref.number, accounts); err != nil {
+				continue
+			}
+		}
+		if account != nil {
+			resolved[parser.AccountKey(ref.number, ref.accountType)] = account.Id
+		}
+	}
+
+	// copies, so the account IDs set here don't leak into the upload
+	originals := make(map[*domain.Transaction]*domain.Transaction, len(transactions))
+	copies := make([]*domain.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		number := "Unknown"
+		if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+			number = *tx.StatementAccountNumber
+		}
+		c := *tx
+		c.AccountID = int(resolved[parser.AccountKey(number, tx.StatementAccountType)])
+		originals[&c] = tx
+		copies = append(copies, &c)
+	}
+
+	reconcileOpts := opts.reconcileOptions()
+	warnings := make(map[*domain.Transaction][]string)
+	for _, m := range reconcile.Find(copies, existingRows(nullClient, userID, copies, statements, reconcileOpts), reconcileOpts) {
+		if m.Statement.AccountID == 0 {
+			continue
+		}
+		e := m.Existing
+		warnings[originals[m.Statement]] = append(warnings[originals[m.Statement]], fmt.Sprintf(
+			"updates #%d %s %.2f %q already in ariand",
+			e.Id, e.GetTxDate().AsTime().Format(time.DateOnly), client.MoneyToFloat(e.GetTxAmount()), e.GetDescription()))
+	}
+	return warnings
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"time"
+
+	"null-statement-parser/internal/domain"
+	"null-statement-parser/internal/parser"
+	"null-statement-parser/internal/session"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// exit codes, so scripts can tell failure kinds apart. 2 is left to the flag
+// package for usage errors.
+const (
+	exitError   = 1
+	exitParse   = 3
+	exitAuth    = 4
+	exitPartial = 5
+)
+
+// jsonOutput is set by the global -output json flag
+var jsonOutput bool
+
+// out receives human-readable progress. With -output json it is stderr, so
+// stdout carries nothing but the result document.
+var out io.Writer = os.Stdout
+
+type fileReport struct {
+	File         string `json:"file"`
+	Transactions int    `json:"transactions"`
+	Processed    bool   `json:"processed"`
+	Error        string `json:"error,omitempty"`
+}
+
+type rowReport struct {
+	ID          int64   `json:"id,omitempty"`
+	Account     string  `json:"account,omitempty"`
+	Date        string  `json:"date,omitempty"`
+	Amount      float64 `json:"amount,omitempty"`
+	Direction   string  `json:"direction,omitempty"`
+	Description string  `json:"description,omitempty"`
+	File        string  `json:"file,omitempty"`
+	Reason      string  `json:"reason"`
+}
+
+type mergeReport struct {
+	Source string `json:"source"`
+	parser.AccountMerge
+}
+
+// result is the document written to stdout with -output json. Commands fill
+// in the fields that apply to them.
+type result struct {
+	Command    string             `json:"command"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Files      []fileReport       `json:"files,omitempty"`
+	Parsed     int                `json:"parsed"`
+	Accounts   map[string]int     `json:"accounts,omitempty"`
+	Created    int                `json:"created"`
+	Reconciled int                `json:"reconciled,omitempty"`
+	Anchored   int                `json:"anchored,omitempty"`
+	Linked     int                `json:"linked,omitempty"`
+	Merged     int                `json:"merged,omitempty"`
+	Merges     []mergeReport      `json:"merges,omitempty"`
+	Deleted    int                `json:"deleted,omitempty"`
+	Skipped    []rowReport        `json:"skipped,omitempty"`
+	Failed     []rowReport        `json:"failed,omitempty"`
+	SessionID  string             `json:"session_id,omitempty"`
+	Sessions   []*session.Session `json:"sessions,omitempty"`
+	Output     string             `json:"output,omitempty"`
+}
+
+var report = &result{}
+
+func setOutputMode(mode string) error {
+	switch mode {
+	case "", "text":
+	case "json":
+		jsonOutput = true
+		out = os.Stderr
+	default:
+		return fmt.Errorf("unknown output mode %q (want text or json)", mode)
+	}
+	return nil
+}
+
+// fatalf logs the error and exits with code, writing the result document
+// first in JSON mode
+func fatalf(code int, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	log.Print(msg)
+
+	if jsonOutput {
+		report.Status = statusName(code)
+		report.Error = msg
+		writeReport()
+	}
+	os.Exit(code)
+}
+
+// finish writes the result document and exits with exitPartial when some rows
+// failed, or exitParse when some input files could not be parsed
+func finish() {
+	code := 0
+	if len(report.Failed) > 0 {
+		code = exitPartial
+	} else if slices.ContainsFunc(report.Files, func(f fileReport) bool { return f.Error != "" }) {
+		code = exitParse
+	}
+
+	if jsonOutput {
+		report.Status = statusName(code)
+		writeReport()
+	}
+	if code != 0 {
+		os.Exit(code)
+	}
+}
+
+func statusName(code int) string {
+	switch code {
+	case 0:
+		return "ok"
+	case exitParse:
+		return "parse_failed"
+	case exitAuth:
+		return "auth_failed"
+	case exitPartial:
+		return "partial"
+	default:
+		return "failed"
+	}
+}
+
+func writeReport() {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("failed to write result: %v", err)
+	}
+}
+
+// classify picks the exit code for an error returned by ariand
+func classify(err error) int {
+	switch status.Code(err) {
+	case codes.Unauthenticated, codes.PermissionDenied:
+		return exitAuth
+	default:
+		return exitError
+	}
+}
+
+func txRow(tx *domain.Transaction, reason string) rowReport {
+	row := rowReport{
+		Account:     "Unknown",
+		Date:        tx.TxDate.Format(time.DateOnly),
+		Amount:      tx.TxAmount,
+		Direction:   tx.TxDirection.String(),
+		Description: tx.TxDesc,
+		File:        tx.SourceFilePath,
+		Reason:      reason,
+	}
+	if tx.StatementAccountNumber != nil && *tx.StatementAccountNumber != "" {
+		row.Account = *tx.StatementAccountNumber
+	}
+	return row
+}
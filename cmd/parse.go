@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"null-statement-parser/internal/normalized"
+)
+
+func runParse(args []string) {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	opts := addParseFlags(flags)
+	outPath := flags.String("o", "", "output file (default: stdout)")
+	outFormat := flags.String("format", "", "json, ndjson or csv (default: by -o extension, else json)")
+	flags.Parse(args)
+
+	report.Command = "parse"
+	if jsonOutput && (*outPath == "" || *outPath == "-") {
+		fatalf(exitError, "-output json needs -o, stdout is reserved for the result")
+	}
+	opts.resolve()
+
+	format, err := normalized.ResolveFormat(*outFormat, *outPath)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	// progress goes to stderr so stdout stays clean for the document
+	transactions, statements := parseInputs(os.Stderr, opts)
+	doc := &normalized.Document{Transactions: transactions, Statements: statements}
+
+	if err := normalized.WriteFile(*outPath, format, doc); err != nil {
+		fatalf(exitError, "write failed: %v", err)
+	}
+
+	if *outPath != "" && *outPath != "-" {
+		fmt.Fprintf(os.Stderr, "wrote %d transactions to %s\n", len(transactions), *outPath)
+		report.Output = *outPath
+	}
+	finish()
+}
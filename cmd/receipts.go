@@ -0,0 +1,275 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/client"
+	pb "null-statement-parser/internal/gen/null/v1"
+	"null-statement-parser/internal/receipt"
+	"null-statement-parser/internal/state"
+)
+
+func runReceipts(args []string) {
+	report.Command = "receipts"
+	if len(args) == 0 {
+		fatalf(exitError, "usage: receipts upload <dir> | receipts link")
+	}
+
+	switch args[0] {
+	case "upload":
+		runReceiptsUpload(args[1:])
+	case "link":
+		runReceiptsLink(args[1:])
+	default:
+		fatalf(exitError, "unknown receipts command: %s", args[0])
+	}
+}
+
+func runReceiptsUpload(args []string) {
+	flags := flag.NewFlagSet("receipts upload", flag.ExitOnError)
+	force := flags.Bool("force", false, "upload files even if they were uploaded before")
+	flags.Parse(args)
+
+	report.Command = "receipts upload"
+	if flags.NArg() != 1 {
+		fatalf(exitError, "usage: receipts upload [-force] <dir>")
+	}
+	dir := flags.Arg(0)
+
+	userID, serverURL, apiKey := credentials()
+
+	files, err := receipt.FindImages(dir)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	if len(files) == 0 {
+		fmt.Fprintf(out, "no receipt images in %s\n", dir)
+		finish()
+		return
+	}
+
+	ledgerPath, err := state.Path("receipts.json")
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	ledger, err := receipt.LoadLedger(ledgerPath)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		fatalf(classify(err), "client failed: %v", err)
+	}
+	defer nullClient.Close()
+
+	uploaded, skipped, failed := 0, 0, 0
+	fail := func(file string, err error) {
+		report.Failed = append(report.Failed, rowReport{File: file, Reason: err.Error()})
+		failed++
+	}
+	for _, file := range files {
+		name := filepath.Base(file)
+
+		hash, err := receipt.HashFile(file)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			fail(file, err)
+			continue
+		}
+
+		if entry, ok := ledger.Lookup(hash); ok && !*force {
+			fmt.Fprintf(out, "  %s: already uploaded as receipt %d\n", name, entry.ReceiptID)
+			report.Skipped = append(report.Skipped, rowReport{ID: entry.ReceiptID, File: file, Reason: "already uploaded"})
+			skipped++
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("ERROR: %v", err)
+			fail(file, err)
+			continue
+		}
+
+		contentType, _ := receipt.ContentType(file)
+		r, err := nullClient.UploadReceipt(userID, data, contentType)
+		if err != nil {
+			if classify(err) == exitAuth {
+				fatalf(exitAuth, "upload failed: %v", err)
+			}
+			log.Printf("ERROR: %s: %v", name, err)
+			fail(file, err)
+			continue
+		}
+
+		ledger.Record(hash, file, r.Id)
+		if err := ledger.Save(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+		uploaded++
+
+		fmt.Fprintf(out, "  %s: receipt %d, %s\n", name, r.Id, describeReceipt(r))
+	}
+
+	fmt.Fprintf(out, "\n%d uploaded, %d skipped, %d failed\n", uploaded, skipped, failed)
+	report.Created = uploaded
+	finish()
+}
+
+func runReceiptsLink(args []string) {
+	opts := receipt.DefaultMatchOptions()
+
+	flags := flag.NewFlagSet("receipts link", flag.ExitOnError)
+	flags.IntVar(&opts.WindowDays, "window", opts.WindowDays, "max days between receipt and transaction date")
+	flags.Float64Var(&opts.TipAllowance, "tip", opts.TipAllowance, "max fraction a transaction may exceed the receipt total by")
+	minScore := flags.Float64("min-score", 0.85, "score needed to link without asking")
+	minMargin := flags.Float64("margin", 0.15, "lead over the runner-up needed to link without asking")
+	maxChoices := flags.Int("choices", 5, "candidates shown for ambiguous receipts")
+	dryRun := flags.Bool("dry-run", false, "print matches without linking")
+	noPrompt := flags.Bool("no-prompt", false, "skip ambiguous receipts instead of asking")
+	flags.Parse(args)
+
+	report.Command = "receipts link"
+	userID, serverURL, apiKey := credentials()
+
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		fatalf(classify(err), "client failed: %v", err)
+	}
+	defer nullClient.Close()
+
+	allReceipts, err := nullClient.ListReceipts(userID, false)
+	if err != nil {
+		fatalf(classify(err), "%v", err)
+	}
+	unlinked, err := nullClient.ListReceipts(userID, true)
+	if err != nil {
+		fatalf(classify(err), "%v", err)
+	}
+
+	// transactions that already carry a receipt are not offered again
+	linkedTx := make(map[int64]bool)
+	for _, r := range allReceipts {
+		if r.TransactionId != nil {
+			linkedTx[r.GetTransactionId()] = true
+		}
+	}
+
+	var matchable []*pb.Receipt
+	var start, end time.Time
+	for _, r := range unlinked {
+		d, ok := receipt.Date(r)
+		if !ok || r.GetTotal() == nil {
+			fmt.Fprintf(out, "  receipt %d: no date or total yet (%s), skipping\n", r.Id, describeReceipt(r))
+			report.Skipped = append(report.Skipped, rowReport{ID: r.Id, Reason: "no date or total yet"})
+			continue
+		}
+		if start.IsZero() || d.Before(start) {
+			start = d
+		}
+		if end.IsZero() || d.After(end) {
+			end = d
+		}
+		matchable = append(matchable, r)
+	}
+
+	if len(matchable) == 0 {
+		fmt.Fprintf(out, "no unlinked receipts to match\n")
+		finish()
+		return
+	}
+
+	window := time.Duration(opts.WindowDays+1) * 24 * time.Hour
+	transactions, err := nullClient.ListTransactions(userID, client.TransactionQuery{
+		Start: start.Add(-window),
+		End:   end.Add(window),
+	})
+	if err != nil {
+		fatalf(classify(err), "%v", err)
+	}
+
+	linked, skipped, failed := 0, 0, 0
+	for _, r := range matchable {
+		var available []*pb.Transaction
+		for _, tx := range transactions {
+			if !linkedTx[tx.Id] {
+				available = append(available, tx)
+			}
+		}
+
+		candidates := receipt.ScoreCandidates(r, available, opts)
+		if len(candidates) == 0 {
+			fmt.Fprintf(out, "  receipt %d: no candidates\n", r.Id)
+			report.Skipped = append(report.Skipped, rowReport{ID: r.Id, Reason: "no candidates"})
+			skipped++
+			continue
+		}
+
+		var txID int64
+		if receipt.IsConfident(candidates, *minScore, *minMargin) {
+			txID = candidates[0].Transaction.Id
+			fmt.Fprintf(out, "  receipt %d: matched transaction %d (score %.2f)\n", r.Id, txID, candidates[0].Score)
+		} else if *noPrompt {
+			fmt.Fprintf(out, "  receipt %d: %d ambiguous candidates, skipping\n", r.Id, len(candidates))
+			report.Skipped = append(report.Skipped, rowReport{ID: r.Id, Reason: "ambiguous"})
+			skipped++
+			continue
+		} else {
+			if len(candidates) > *maxChoices {
+				candidates = candidates[:*maxChoices]
+			}
+			txID, err = receipt.PromptForLink(r, candidates)
+			if err != nil {
+				fatalf(exitError, "link prompt failed: %v", err)
+			}
+			if txID == 0 {
+				report.Skipped = append(report.Skipped, rowReport{ID: r.Id, Reason: "skipped in prompt"})
+				skipped++
+				continue
+			}
+		}
+
+		if *dryRun {
+			linkedTx[txID] = true
+			linked++
+			continue
+		}
+
+		if _, err := nullClient.UpdateReceipt(userID, r.Id, &txID); err != nil {
+			log.Printf("ERROR: receipt %d: %v", r.Id, err)
+			report.Failed = append(report.Failed, rowReport{ID: r.Id, Reason: err.Error()})
+			failed++
+			continue
+		}
+		linkedTx[txID] = true
+		linked++
+	}
+
+	fmt.Fprintf(out, "\n%d linked, %d skipped, %d failed\n", linked, skipped, failed)
+	report.Linked = linked
+	finish()
+}
+
+func describeReceipt(r *pb.Receipt) string {
+	status := strings.ToLower(strings.TrimPrefix(r.Status.String(), "RECEIPT_STATUS_"))
+	desc := status
+
+	if r.Confidence != nil {
+		desc += fmt.Sprintf(" (confidence %.0f%%)", r.GetConfidence()*100)
+	}
+	if r.Merchant != nil {
+		desc += fmt.Sprintf(", %s", r.GetMerchant())
+	}
+	if total := r.GetTotal(); total != nil {
+		desc += fmt.Sprintf(", %.2f %s", client.MoneyToFloat(total), total.CurrencyCode)
+	}
+
+	return desc
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/session"
+)
+
+const deleteBatchSize = 500
+
+func runImportSessions(args []string) {
+	flags := flag.NewFlagSet("import sessions", flag.ExitOnError)
+	flags.Parse(args)
+
+	report.Command = "import sessions"
+	sessions, err := session.List()
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	report.Sessions = sessions
+	if len(sessions) == 0 {
+		fmt.Fprintf(out, "no import sessions recorded\n")
+		finish()
+		return
+	}
+
+	for _, s := range sessions {
+		undone := ""
+		if !s.UndoneAt.IsZero() {
+			undone = ", undone"
+		}
+		fmt.Fprintf(out, "%s  %s  %d transactions, %d files%s\n",
+			s.ID, s.StartedAt.Format(time.DateTime), len(s.TransactionIDs), len(s.Files), undone)
+	}
+	finish()
+}
+
+// parseInterspersed parses flags before and after positional arguments, which
+// flag.Parse stops at, and returns the positional ones
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+	for {
+		flags.Parse(args)
+		if flags.NArg() == 0 {
+			return positional
+		}
+		// everything after "--" is positional
+		if parsed := len(args) - flags.NArg(); parsed > 0 && args[parsed-1] == "--" {
+			return append(positional, flags.Args()...)
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+}
+
+func runImportUndo(args []string) {
+	flags := flag.NewFlagSet("import undo", flag.ExitOnError)
+	deleteAccounts := flags.Bool("delete-accounts", false, "also delete accounts the import created")
+	yes := flags.Bool("yes", false, "skip the confirmation prompt")
+	positional := parseInterspersed(flags, args)
+
+	report.Command = "import undo"
+	if len(positional) != 1 {
+		fatalf(exitError, "usage: import undo [-delete-accounts] [-yes] <session>")
+	}
+
+	sess, err := session.Load(positional[0])
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	report.SessionID = sess.ID
+	if !sess.UndoneAt.IsZero() {
+		fatalf(exitError, "session %s was already undone at %s", sess.ID, sess.UndoneAt.Format(time.DateTime))
+	}
+
+	userID, serverURL, apiKey := credentials()
+
+	fmt.Fprintf(out, "session %s from %s\n", sess.ID, sess.StartedAt.Format(time.DateTime))
+	for _, file := range sess.Files {
+		fmt.Fprintf(out, "  %s\n", file)
+	}
+	fmt.Fprintf(out, "will delete %d transactions and remove %d aliases\n", len(sess.TransactionIDs), len(sess.Aliases))
+	if len(sess.CreatedAccountIDs) > 0 {
+		if *deleteAccounts {
+			fmt.Fprintf(out, "will delete %d accounts created by the import\n", len(sess.CreatedAccountIDs))
+		} else {
+			fmt.Fprintf(out, "keeping %d accounts created by the import (pass -delete-accounts to remove them)\n", len(sess.CreatedAccountIDs))
+		}
+	}
+	if len(sess.ReconciledIDs) > 0 {
+		fmt.Fprintf(out, "note: %d reconciled rows were updated in place and are left as they are\n", len(sess.ReconciledIDs))
+	}
+
+	if !*yes {
+		fmt.Fprintf(out, "\nundo? (y/N): ")
+		if !readYes() {
+			finish()
+			return
+		}
+	}
+
+	nullClient, err := client.NewClient(serverURL, "", apiKey)
+	if err != nil {
+		fatalf(classify(err), "client failed: %v", err)
+	}
+	defer nullClient.Close()
+
+	fail := func(row rowReport, err error) {
+		log.Printf("ERROR: %v", err)
+		row.Reason = err.Error()
+		report.Failed = append(report.Failed, row)
+	}
+
+	deleted := int64(0)
+	for i := 0; i < len(sess.TransactionIDs); i += deleteBatchSize {
+		end := min(i+deleteBatchSize, len(sess.TransactionIDs))
+		affected, err := nullClient.DeleteTransactions(userID, sess.TransactionIDs[i:end])
+		if err != nil {
+			for _, id := range sess.TransactionIDs[i:end] {
+				fail(rowReport{ID: id, Description: "transaction"}, err)
+			}
+			continue
+		}
+		deleted += affected
+	}
+	report.Deleted = int(deleted)
+
+	for _, alias := range sess.Aliases {
+		if err := nullClient.RemoveAccountAlias(userID, alias.AccountID, alias.Alias); err != nil {
+			fail(rowReport{ID: alias.AccountID, Description: "alias " + alias.Alias}, err)
+		}
+	}
+
+	if *deleteAccounts {
+		for _, id := range sess.CreatedAccountIDs {
+			if err := nullClient.DeleteAccount(userID, id); err != nil {
+				fail(rowReport{ID: id, Description: "account"}, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d transactions deleted, %d errors\n", deleted, len(report.Failed))
+	if len(report.Failed) == 0 {
+		sess.UndoneAt = time.Now()
+		if err := sess.Save(); err != nil {
+			log.Printf("WARN: %v", err)
+		}
+	}
+	finish()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"flag"
+	"slices"
+	"testing"
+)
+
+func TestParseInterspersed(t *testing.T) {
+	tests := []struct {
+		args       []string
+		yes        bool
+		positional []string
+	}{
+		{[]string{"-yes", "abc123"}, true, []string{"abc123"}},
+		{[]string{"abc123", "-yes"}, true, []string{"abc123"}},
+		{[]string{"abc123"}, false, []string{"abc123"}},
+		{[]string{"abc123", "def456", "-yes"}, true, []string{"abc123", "def456"}},
+		{[]string{"--", "-yes"}, false, []string{"-yes"}},
+	}
+
+	for _, tt := range tests {
+		flags := flag.NewFlagSet("test", flag.ContinueOnError)
+		yes := flags.Bool("yes", false, "")
+		positional := parseInterspersed(flags, tt.args)
+		if *yes != tt.yes || !slices.Equal(positional, tt.positional) {
+			t.Errorf("%q: got -yes=%v %q, want -yes=%v %q", tt.args, *yes, positional, tt.yes, tt.positional)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"null-statement-parser/internal/domain"
+	"null-statement-parser/internal/email"
+	"null-statement-parser/internal/mapping"
+	"null-statement-parser/internal/parser"
+	"null-statement-parser/internal/state"
+)
+
+const defaultWatchInterval = 5 * time.Minute
+
+// runWatch polls the profile's IMAP folder for alert emails and imports them
+// as they arrive, until killed
+func runWatch(args []string) {
+	defaultInterval := defaultWatchInterval
+	if settings.IMAP.Interval != "" {
+		var err error
+		if defaultInterval, err = time.ParseDuration(settings.IMAP.Interval); err != nil {
+			fatalf(exitError, "invalid imap.interval %q: %v", settings.IMAP.Interval, err)
+		}
+	}
+
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	folder := flags.String("folder", settings.IMAP.Folder, "IMAP folder to poll (default INBOX)")
+	moveTo := flags.String("move-to", settings.IMAP.MoveTo, "folder imported alerts are moved to (default: leave them)")
+	interval := flags.Duration("interval", defaultInterval, "time between polls, and the longest IDLE wait")
+	once := flags.Bool("once", false, "poll once and exit")
+	mappingPath := flags.String("mapping", settings.AccountMapping, "account mapping file (JSON) used before prompting")
+	dateSource := flags.String("date-source", string(domain.TransactionDate), "date sent to ariand: transaction or posting")
+	flags.Parse(args)
+
+	report.Command = "watch"
+	if *interval <= 0 {
+		fatalf(exitError, "-interval must be positive")
+	}
+	source, err := domain.ParseDateSource(*dateSource)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	accountMapping, err := mapping.LoadFile(*mappingPath)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	server, username, password, err := settings.IMAPCredentials()
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	userID, serverURL, apiKey := credentials()
+
+	config := email.IMAPConfig{
+		Server:             server,
+		Username:           username,
+		Password:           password,
+		Folder:             *folder,
+		Security:           settings.IMAP.Security,
+		InsecureSkipVerify: settings.IMAP.InsecureSkipVerify,
+	}
+	if config.Folder == "" {
+		config.Folder = "INBOX"
+	}
+
+	checkpointPath, err := state.Path("imap-" + url.PathEscape(username+"@"+server+"/"+config.Folder) + ".json")
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+
+	w := &watcher{
+		config:         config,
+		moveTo:         *moveTo,
+		checkpointPath: checkpointPath,
+		// nobody is there to answer a prompt, so alerts of unmapped accounts
+		// fail until the mapping file names their account
+		upload: func(transactions []*domain.Transaction) error {
+			return uploadTransactions(userID, serverURL, apiKey, uploadOptions{
+				dateSource:     source,
+				reconcile:      true,
+				accountMapping: accountMapping,
+				batchSize:      settings.BatchSize,
+			}, transactions, nil)
+		},
+	}
+
+	// a bad login or folder won't fix itself, so only later failures are
+	// retried
+	mailbox, err := email.DialIMAP(config)
+	if err != nil {
+		fatalf(exitError, "%v", err)
+	}
+	fmt.Fprintf(out, "watching %s on %s\n", config.Folder, server)
+
+	for {
+		if err := w.poll(mailbox); err != nil {
+			if *once {
+				fatalf(exitError, "%v", err)
+			}
+			log.Printf("WARN: %v", err)
+		}
+		if *once {
+			mailbox.Close()
+			finish()
+			return
+		}
+
+		err := mailbox.Wait(*interval)
+		if err == nil {
+			continue
+		}
+
+		log.Printf("WARN: %v, reconnecting", err)
+		mailbox.Close()
+		for {
+			time.Sleep(*interval)
+			if mailbox, err = email.DialIMAP(config); err == nil {
+				break
+			}
+			log.Printf("WARN: %v", err)
+		}
+	}
+}
+
+type watcher struct {
+	config         email.IMAPConfig
+	moveTo         string
+	checkpointPath string
+	upload         func([]*domain.Transaction) error
+}
+
+// poll imports the alerts that arrived since the last checkpoint. The
+// checkpoint only moves once the upload went through, so a failed upload is
+// retried on the next poll; alerts that made it the first time are then
+// skipped by their Message-ID.
+func (w *watcher) poll(mailbox *email.Mailbox) error {
+	// the report covers one poll, so it doesn't grow for as long as the
+	// watch runs
+	*report = result{Command: report.Command}
+
+	checkpoint, err := email.LoadCheckpoint(w.checkpointPath)
+	if err != nil {
+		return err
+	}
+
+	messages, next, err := mailbox.Fetch(checkpoint)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+	if checkpoint.UIDValidity != 0 && checkpoint.UIDValidity != next.UIDValidity {
+		log.Printf("WARN: %s was rebuilt by the server (UIDVALIDITY changed), reading it again", w.config.Folder)
+	}
+
+	var alerts []*domain.Transaction
+	var uids []uint32
+	skipped := 0
+	for _, msg := range messages {
+		tx, err := email.ParseMessage(msg)
+		switch {
+		case err != nil:
+			log.Printf("ERROR: %v", err)
+			report.Files = append(report.Files, fileReport{File: msg.Location, Error: err.Error()})
+		case tx == nil:
+			skipped++
+		default:
+			alerts = append(alerts, tx)
+			uids = append(uids, msg.UID)
+		}
+	}
+	fmt.Fprintf(out, "\n%s: %d new messages, alerts: %d, other messages: %d\n", time.Now().Format(time.DateTime), len(messages), len(alerts), skipped)
+
+	if len(alerts) > 0 {
+		alerts, _ = parser.Deduplicate(alerts)
+		report.Parsed += len(alerts)
+
+		if err := w.upload(alerts); err != nil {
+			return fmt.Errorf("upload failed, retrying on the next poll: %w", err)
+		}
+		if len(report.Failed) > 0 {
+			return fmt.Errorf("%d alerts failed to upload, retrying on the next poll", len(report.Failed))
+		}
+	}
+
+	if err := next.Save(w.checkpointPath); err != nil {
+		return err
+	}
+
+	if w.moveTo != "" && len(uids) > 0 {
+		if err := mailbox.Move(uids, w.moveTo); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "moved %d alerts to %s\n", len(uids), w.moveTo)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"null-statement-parser/internal/domain"
+	"null-statement-parser/internal/email"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+const (
+	testAlert = "From: RBC Alerts <notify@alerts.rbc.com>\r\n" +
+		"Message-ID: <alert-1@rbc.com>\r\n" +
+		"Date: Wed, 15 Jan 2025 10:24:00 -0500\r\n" +
+		"Subject: Purchase alert\r\n\r\n" +
+		"A purchase of $23.45 was made at TIM HORTONS #1234 on your RBC Visa card ending in 1234 on January 15, 2025 at 10:23 AM.\r\n"
+	testNewsletter = "From: news@example.com\r\n" +
+		"Message-ID: <news-1@example.com>\r\n" +
+		"Subject: Newsletter\r\n\r\nhello\r\n"
+)
+
+// testMailbox starts an in-memory IMAP server holding the given messages in
+// "Alerts", and connects to it
+func testMailbox(t *testing.T, messages ...string) (*imapmemserver.User, email.IMAPConfig, *email.Mailbox) {
+	t.Helper()
+
+	memServer := imapmemserver.New()
+	user := imapmemserver.NewUser("alerts@example.com", "secret")
+	if err := user.Create("Alerts", nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, raw := range messages {
+		if _, err := user.Append("Alerts", bytes.NewReader([]byte(raw)), &imap.AppendOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	memServer.AddUser(user)
+
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(*imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			return memServer.NewSession(), nil, nil
+		},
+		Caps:         imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapIMAP4rev2: {}},
+		InsecureAuth: true,
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	config := email.IMAPConfig{
+		Server:   listener.Addr().String(),
+		Username: "alerts@example.com",
+		Password: "secret",
+		Folder:   "Alerts",
+		Security: "none",
+	}
+	mailbox, err := email.DialIMAP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mailbox.Close() })
+	return user, config, mailbox
+}
+
+func numMessages(t *testing.T, user *imapmemserver.User, folder string) uint32 {
+	t.Helper()
+	status, err := user.Status(folder, &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *status.NumMessages
+}
+
+func TestPollRetriesFailedUpload(t *testing.T) {
+	out = io.Discard
+	user, config, mailbox := testMailbox(t, testAlert, testNewsletter)
+
+	var uploaded []*domain.Transaction
+	uploadErr := errors.New("ariand unavailable")
+	w := &watcher{
+		config:         config,
+		moveTo:         "Imported",
+		checkpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+		upload: func(transactions []*domain.Transaction) error {
+			uploaded = append(uploaded, transactions...)
+			return uploadErr
+		},
+	}
+
+	if err := w.poll(mailbox); err == nil {
+		t.Fatal("poll succeeded with the upload failing")
+	}
+	if cp, _ := email.LoadCheckpoint(w.checkpointPath); cp.LastUID != 0 {
+		t.Fatalf("checkpoint moved to %+v after a failed upload", cp)
+	}
+	if n := numMessages(t, user, "Alerts"); n != 2 {
+		t.Fatalf("%d messages left in Alerts after a failed upload, want 2", n)
+	}
+
+	uploadErr = nil
+	uploaded = nil
+	if err := w.poll(mailbox); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploaded) != 1 || uploaded[0].EmailID != "alert-1@rbc.com" || uploaded[0].TxAmount != 23.45 {
+		t.Fatalf("uploaded %+v, want the purchase alert", uploaded)
+	}
+	if cp, _ := email.LoadCheckpoint(w.checkpointPath); cp.LastUID != 2 {
+		t.Fatalf("checkpoint is %+v, want last UID 2", cp)
+	}
+	// only the alert is moved, the newsletter stays
+	if n := numMessages(t, user, "Alerts"); n != 1 {
+		t.Fatalf("%d messages left in Alerts, want 1", n)
+	}
+	if n := numMessages(t, user, "Imported"); n != 1 {
+		t.Fatalf("%d messages in Imported, want 1", n)
+	}
+
+	uploaded = nil
+	if err := w.poll(mailbox); err != nil {
+		t.Fatal(err)
+	}
+	if len(uploaded) != 0 {
+		t.Fatalf("uploaded %d alerts again", len(uploaded))
+	}
+}
+
+func TestPollFailsOnFailedRows(t *testing.T) {
+	out = io.Discard
+	_, config, mailbox := testMailbox(t, testAlert)
+
+	w := &watcher{
+		config:         config,
+		checkpointPath: filepath.Join(t.TempDir(), "checkpoint.json"),
+		upload: func(transactions []*domain.Transaction) error {
+			for _, tx := range transactions {
+				report.Failed = append(report.Failed, txRow(tx, "no ariand account"))
+			}
+			return nil
+		},
+	}
+
+	for range 2 {
+		if err := w.poll(mailbox); err == nil {
+			t.Fatal("poll succeeded with rows failing")
+		}
+		// each poll starts a fresh report
+		if len(report.Failed) != 1 {
+			t.Fatalf("report has %d failed rows, want 1", len(report.Failed))
+		}
+	}
+	if cp, _ := email.LoadCheckpoint(w.checkpointPath); cp.LastUID != 0 {
+		t.Fatalf("checkpoint moved to %+v with rows failing", cp)
+	}
+}
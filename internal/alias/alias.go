@@ -0,0 +1,97 @@
+// Package alias derives the forms an account number takes across sources, so
+// a chequing account printed as "05172-5163878" on its statement, exported as
+// "5163878" in a CSV and shown as "****3878" in an alert is one account
+package alias
+
+import (
+	"strings"
+	"unicode"
+)
+
+// maskChars are the characters banks hide digits behind
+const maskChars = "*Xx•#"
+
+// Canonical returns the digits of an account number, or the number as given
+// when it is masked or has no digits
+func Canonical(number string) string {
+	number = strings.TrimSpace(number)
+	digits := Digits(number)
+	if digits == "" || Masked(number) {
+		return number
+	}
+	return digits
+}
+
+// Masked reports whether a number hides some of its digits
+func Masked(number string) bool {
+	return strings.ContainsAny(number, maskChars)
+}
+
+// Digits drops everything but the digits
+func Digits(number string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, number)
+}
+
+// Last4 returns the last 4 digits, or all of them when there are fewer
+func Last4(number string) string {
+	digits := Digits(number)
+	if len(digits) > 4 {
+		return digits[len(digits)-4:]
+	}
+	return digits
+}
+
+// Variants returns every form of an account number worth registering as an
+// alias, most specific first: the number as given, its canonical form, the
+// account part without the transit or branch prefix, masked forms and the
+// last 4 digits
+func Variants(number string) []string {
+	number = strings.TrimSpace(number)
+	if number == "" {
+		return nil
+	}
+
+	var variants []string
+	seen := make(map[string]bool)
+	add := func(v string) {
+		key := strings.ToLower(v)
+		if v != "" && !seen[key] {
+			seen[key] = true
+			variants = append(variants, v)
+		}
+	}
+
+	add(number)
+	add(Canonical(number))
+
+	if !Masked(number) {
+		// "05172-5163878" is transit 05172 and account 5163878
+		parts := strings.FieldsFunc(number, func(r rune) bool { return r == '-' || r == ' ' })
+		if len(parts) > 1 {
+			if account := Digits(parts[len(parts)-1]); len(account) > 4 {
+				add(account)
+			}
+		}
+	}
+
+	last4 := Last4(number)
+	if len(last4) == 4 {
+		add("****" + last4)
+		add(last4)
+	}
+	return variants
+}
+
+// IsPartial reports whether variant is a masked or last-4 form of number,
+// which a card and a bank account can share
+func IsPartial(variant, number string) bool {
+	if Masked(variant) {
+		return true
+	}
+	return variant == Last4(number) && Canonical(number) != variant
+}
@@ -0,0 +1,52 @@
+package alias
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestVariants(t *testing.T) {
+	tests := []struct {
+		number string
+		want   []string
+	}{
+		{"05172-5163878", []string{"05172-5163878", "051725163878", "5163878", "****3878", "3878"}},
+		{" 4510123412341234 ", []string{"4510123412341234", "****1234", "1234"}},
+		{"4500********1234", []string{"4500********1234", "****1234", "1234"}},
+		{"****1234", []string{"****1234", "1234"}},
+		{"1234", []string{"1234", "****1234"}},
+		{"123", []string{"123"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Variants(tt.number); !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.number, got, tt.want)
+		}
+	}
+}
+
+func TestIsPartial(t *testing.T) {
+	tests := []struct {
+		variant string
+		number  string
+		want    bool
+	}{
+		{"05172-5163878", "05172-5163878", false},
+		{"051725163878", "05172-5163878", false},
+		{"5163878", "05172-5163878", false},
+		{"****3878", "05172-5163878", true},
+		{"3878", "05172-5163878", true},
+		{"4500********1234", "4500********1234", true},
+		{"1234", "4500********1234", true},
+		// a 4-digit number is all there is
+		{"1234", "1234", false},
+		{"****1234", "1234", true},
+	}
+
+	for _, tt := range tests {
+		if got := IsPartial(tt.variant, tt.number); got != tt.want {
+			t.Errorf("%q of %q: got %v, want %v", tt.variant, tt.number, got, tt.want)
+		}
+	}
+}
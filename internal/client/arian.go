@@ -5,28 +5,36 @@ import (
 	"crypto/tls"
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"null-statement-parser/internal/domain"
 	pb "null-statement-parser/internal/gen/null/v1"
 
 	"github.com/charmbracelet/log"
-	money "google.golang.org/genproto/googleapis/type/money"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Client struct {
-	conn          *grpc.ClientConn
-	accountClient pb.AccountServiceClient
-	txClient      pb.TransactionServiceClient
-	userClient    pb.UserServiceClient
-	authToken     string
-	log           *log.Logger
+	conn           *grpc.ClientConn
+	accountClient  pb.AccountServiceClient
+	txClient       pb.TransactionServiceClient
+	userClient     pb.UserServiceClient
+	receiptClient  pb.ReceiptServiceClient
+	categoryClient pb.CategoryServiceClient
+	authToken      string
+	log            *log.Logger
+
+	// categoryIDs caches category slugs, loaded on first use
+	categoryIDs map[string]int64
+	dateSource  domain.DateSource
 }
 
 func NewClient(serverURL, _, authToken string) (*Client, error) {
@@ -43,15 +51,23 @@ func NewClient(serverURL, _, authToken string) (*Client, error) {
 	}
 
 	return &Client{
-		conn:          conn,
-		accountClient: pb.NewAccountServiceClient(conn),
-		txClient:      pb.NewTransactionServiceClient(conn),
-		userClient:    pb.NewUserServiceClient(conn),
-		authToken:     authToken,
-		log:           log.NewWithOptions(os.Stderr, log.Options{Prefix: "grpc-client"}),
+		conn:           conn,
+		accountClient:  pb.NewAccountServiceClient(conn),
+		txClient:       pb.NewTransactionServiceClient(conn),
+		userClient:     pb.NewUserServiceClient(conn),
+		receiptClient:  pb.NewReceiptServiceClient(conn),
+		categoryClient: pb.NewCategoryServiceClient(conn),
+		authToken:      authToken,
+		log:            log.NewWithOptions(os.Stderr, log.Options{Prefix: "grpc-client"}),
 	}, nil
 }
 
+// SetDateSource selects whether uploaded transactions are dated by the
+// transaction or the posting date, the default is the transaction date
+func (c *Client) SetDateSource(source domain.DateSource) {
+	c.dateSource = source
+}
+
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
@@ -76,27 +92,59 @@ func (c *Client) GetAccounts(userID string) ([]*pb.Account, error) {
 	return resp.Accounts, nil
 }
 
-func (c *Client) CreateAccount(userID, accountName, bank string, accountType pb.AccountType, mainCurrency string) (*pb.Account, error) {
+// NewAccount is an account to create. FriendlyName and Colors are optional,
+// AnchorBalance is the balance ariand works the running balance out from.
+type NewAccount struct {
+	Name          string
+	FriendlyName  string
+	Bank          string
+	Type          pb.AccountType
+	Currency      string
+	Colors        []string
+	AnchorBalance float64
+}
+
+func (c *Client) CreateAccount(userID string, account NewAccount) (*pb.Account, error) {
 	ctx := c.withAuth(context.Background())
-	resp, err := c.accountClient.CreateAccount(ctx, &pb.CreateAccountRequest{
-		UserId:       userID,
-		Name:         accountName,
-		Bank:         bank,
-		Type:         accountType,
-		MainCurrency: mainCurrency,
-		AnchorBalance: &money.Money{
-			CurrencyCode: mainCurrency,
-			Units:        0,
-			Nanos:        0,
-		},
-	})
+	req := &pb.CreateAccountRequest{
+		UserId:        userID,
+		Name:          account.Name,
+		Bank:          account.Bank,
+		Type:          account.Type,
+		MainCurrency:  account.Currency,
+		AnchorBalance: NewMoney(account.AnchorBalance, account.Currency),
+		Colors:        account.Colors,
+	}
+	if account.FriendlyName != "" {
+		req.FriendlyName = &account.FriendlyName
+	}
+
+	resp, err := c.accountClient.CreateAccount(ctx, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create account: %w", err)
 	}
-	c.log.Info("successfully created account", "account_name", accountName, "account_type", accountType, "account_id", resp.Account.Id)
+	c.log.Info("successfully created account", "account_name", account.Name, "account_type", account.Type, "account_id", resp.Account.Id)
 	return resp.Account, nil
 }
 
+// SetAnchor sets an account's anchor balance, the known balance on date that
+// ariand works the running balance out from
+func (c *Client) SetAnchor(userID string, accountID int64, balance float64, currency string, date time.Time) error {
+	ctx := c.withAuth(context.Background())
+	_, err := c.accountClient.UpdateAccount(ctx, &pb.UpdateAccountRequest{
+		UserId:        userID,
+		Id:            accountID,
+		AnchorBalance: NewMoney(balance, currency),
+		AnchorDate:    timestamppb.New(date),
+		UpdateMask:    &fieldmaskpb.FieldMask{Paths: []string{"anchor_balance", "anchor_date"}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update anchor of account %d: %w", accountID, err)
+	}
+	c.log.Info("updated account anchor", "account_id", accountID, "balance", balance, "date", date.Format(time.DateOnly))
+	return nil
+}
+
 func (c *Client) FindAccountByAlias(userID, alias string) (*pb.Account, error) {
 	ctx := c.withAuth(context.Background())
 	resp, err := c.accountClient.FindAccountByAlias(ctx, &pb.FindAccountByAliasRequest{UserId: userID, Alias: alias})
@@ -124,9 +172,66 @@ func (c *Client) AddAccountAlias(userID string, accountID int64, alias string) e
 	return nil
 }
 
-func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Transaction) (int32, []error) {
+// SetAccountAliases replaces an account's aliases with aliases
+func (c *Client) SetAccountAliases(userID string, accountID int64, aliases []string) error {
+	ctx := c.withAuth(context.Background())
+	_, err := c.accountClient.SetAccountAliases(ctx, &pb.SetAccountAliasesRequest{
+		UserId:    userID,
+		AccountId: accountID,
+		Aliases:   aliases,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to set account aliases: %w", err)
+	}
+	c.log.Info("set account aliases", "account_id", accountID, "aliases", len(aliases))
+	return nil
+}
+
+func (c *Client) RemoveAccountAlias(userID string, accountID int64, alias string) error {
+	ctx := c.withAuth(context.Background())
+	_, err := c.accountClient.RemoveAccountAlias(ctx, &pb.RemoveAccountAliasRequest{
+		UserId:    userID,
+		AccountId: accountID,
+		Alias:     alias,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove account alias: %w", err)
+	}
+	c.log.Info("removed alias from account", "account_id", accountID, "alias", alias)
+	return nil
+}
+
+// MergeAccounts merges the secondary account into the primary, returning the
+// merged account and how many transactions moved
+func (c *Client) MergeAccounts(userID string, primaryID, secondaryID int64) (*pb.Account, int64, error) {
+	ctx := c.withAuth(context.Background())
+	resp, err := c.accountClient.MergeAccounts(ctx, &pb.MergeAccountsRequest{
+		UserId:             userID,
+		PrimaryAccountId:   primaryID,
+		SecondaryAccountId: secondaryID,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to merge account %d into %d: %w", secondaryID, primaryID, err)
+	}
+	c.log.Info("merged accounts", "primary_id", primaryID, "secondary_id", secondaryID, "transactions_moved", resp.TransactionsMoved)
+	return resp.Account, resp.TransactionsMoved, nil
+}
+
+func (c *Client) DeleteAccount(userID string, accountID int64) error {
+	ctx := c.withAuth(context.Background())
+	_, err := c.accountClient.DeleteAccount(ctx, &pb.DeleteAccountRequest{UserId: userID, Id: accountID})
+	if err != nil {
+		return fmt.Errorf("failed to delete account: %w", err)
+	}
+	c.log.Info("deleted account", "account_id", accountID)
+	return nil
+}
+
+// CreateTransactionsBulk creates transactions in one request and returns the
+// created count along with the IDs of the created rows
+func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Transaction) (int32, []int64, []error) {
 	if len(transactions) == 0 {
-		return 0, nil
+		return 0, nil, nil
 	}
 
 	ctx := c.withAuth(context.Background())
@@ -135,12 +240,8 @@ func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Tr
 	for _, tx := range transactions {
 		input := &pb.TransactionInput{
 			AccountId: int64(tx.AccountID),
-			TxDate:    timestamppb.New(tx.TxDate),
-			TxAmount: &money.Money{
-				CurrencyCode: tx.TxCurrency,
-				Units:        int64(tx.TxAmount),
-				Nanos:        int32((tx.TxAmount - float64(int64(tx.TxAmount))) * 1e9),
-			},
+			TxDate:    timestamppb.New(tx.Date(c.dateSource)),
+			TxAmount:  NewMoney(tx.TxAmount, tx.TxCurrency),
 			Direction: c.convertDirection(tx.TxDirection),
 		}
 		if tx.TxDesc != "" {
@@ -149,8 +250,11 @@ func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Tr
 		if tx.Merchant != "" {
 			input.Merchant = &tx.Merchant
 		}
-		if tx.UserNotes != "" {
-			input.UserNotes = &tx.UserNotes
+		if notes := tx.UploadNotes(); notes != "" {
+			input.UserNotes = &notes
+		}
+		if categoryID, ok := c.categoryID(userID, tx.Category); ok {
+			input.CategoryId = &categoryID
 		}
 		inputs = append(inputs, input)
 	}
@@ -162,13 +266,137 @@ func (c *Client) CreateTransactionsBulk(userID string, transactions []*domain.Tr
 	if err != nil {
 		if status.Code(err) == codes.AlreadyExists {
 			c.log.Info("skipping duplicate transactions")
-			return 0, nil
+			return 0, nil, nil
 		}
-		return 0, []error{fmt.Errorf("failed to create transactions: %w", err)}
+		return 0, nil, []error{fmt.Errorf("failed to create transactions: %w", err)}
+	}
+
+	ids := make([]int64, 0, len(resp.Transactions))
+	for _, created := range resp.Transactions {
+		ids = append(ids, created.Id)
 	}
 
 	c.log.Info("transactions created successfully", "count", resp.CreatedCount)
-	return resp.CreatedCount, nil
+	return resp.CreatedCount, ids, nil
+}
+
+// DeleteTransactions deletes transactions by ID and returns the number of
+// rows removed
+func (c *Client) DeleteTransactions(userID string, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	ctx := c.withAuth(context.Background())
+	resp, err := c.txClient.DeleteTransaction(ctx, &pb.DeleteTransactionRequest{UserId: userID, Ids: ids})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete transactions: %w", err)
+	}
+	c.log.Info("deleted transactions", "count", resp.AffectedRows)
+	return resp.AffectedRows, nil
+}
+
+func (c *Client) GetCategories(userID string) ([]*pb.Category, error) {
+	ctx := c.withAuth(context.Background())
+	resp, err := c.categoryClient.ListCategories(ctx, &pb.ListCategoriesRequest{UserId: userID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list categories: %w", err)
+	}
+	c.log.Info("successfully fetched categories", "count", len(resp.Categories))
+	return resp.Categories, nil
+}
+
+// categoryID resolves a category name to an ariand category by slug,
+// ignoring case. Unknown categories are left for ariand's own rules.
+func (c *Client) categoryID(userID, category string) (int64, bool) {
+	if category == "" {
+		return 0, false
+	}
+
+	if c.categoryIDs == nil {
+		c.categoryIDs = make(map[string]int64)
+		categories, err := c.GetCategories(userID)
+		if err != nil {
+			c.log.Warn("categories unavailable, uploading without them", "err", err)
+		}
+		for _, cat := range categories {
+			c.categoryIDs[strings.ToLower(cat.Slug)] = cat.Id
+		}
+	}
+
+	id, ok := c.categoryIDs[strings.ToLower(category)]
+	return id, ok
+}
+
+// UpdateTransaction overwrites an existing transaction's date, amount,
+// currency, description, merchant and notes with the values from tx
+func (c *Client) UpdateTransaction(userID string, transactionID int64, tx *domain.Transaction) error {
+	ctx := c.withAuth(context.Background())
+
+	req := &pb.UpdateTransactionRequest{
+		UserId:      userID,
+		Id:          transactionID,
+		TxDate:      timestamppb.New(tx.Date(c.dateSource)),
+		TxAmount:    NewMoney(tx.TxAmount, tx.TxCurrency),
+		Description: &tx.TxDesc,
+		UserNotes:   &tx.UserNotes,
+		UpdateMask:  &fieldmaskpb.FieldMask{Paths: []string{"tx_date", "tx_amount", "description", "user_notes"}},
+	}
+	if tx.Merchant != "" {
+		req.Merchant = &tx.Merchant
+		req.UpdateMask.Paths = append(req.UpdateMask.Paths, "merchant")
+	}
+
+	if _, err := c.txClient.UpdateTransaction(ctx, req); err != nil {
+		return fmt.Errorf("failed to update transaction %d: %w", transactionID, err)
+	}
+	c.log.Info("updated transaction", "transaction_id", transactionID)
+	return nil
+}
+
+// TransactionQuery narrows ListTransactions, zero values mean no filter
+type TransactionQuery struct {
+	AccountID int64
+	Start     time.Time
+	End       time.Time
+}
+
+const transactionPageSize = 500
+
+// ListTransactions pages through every transaction matching the query
+func (c *Client) ListTransactions(userID string, query TransactionQuery) ([]*pb.Transaction, error) {
+	ctx := c.withAuth(context.Background())
+
+	req := &pb.ListTransactionsRequest{
+		UserId: userID,
+		Limit:  ptr(int32(transactionPageSize)),
+	}
+	if query.AccountID != 0 {
+		req.AccountId = ptr(query.AccountID)
+	}
+	if !query.Start.IsZero() {
+		req.StartDate = timestamppb.New(query.Start)
+	}
+	if !query.End.IsZero() {
+		req.EndDate = timestamppb.New(query.End)
+	}
+
+	var transactions []*pb.Transaction
+	for {
+		resp, err := c.txClient.ListTransactions(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list transactions: %w", err)
+		}
+		transactions = append(transactions, resp.Transactions...)
+
+		if resp.NextCursor == nil || len(resp.Transactions) == 0 {
+			break
+		}
+		req.Cursor = resp.NextCursor
+	}
+
+	c.log.Info("successfully fetched transactions", "count", len(transactions))
+	return transactions, nil
 }
 
 func (c *Client) withAuth(ctx context.Context) context.Context {
@@ -0,0 +1,35 @@
+package client
+
+import (
+	"math"
+
+	money "google.golang.org/genproto/googleapis/type/money"
+)
+
+// NewMoney converts a decimal amount into google.type.Money, rounding to cents
+func NewMoney(amount float64, currency string) *money.Money {
+	cents := int64(math.Round(amount * 100))
+	return &money.Money{
+		CurrencyCode: currency,
+		Units:        cents / 100,
+		Nanos:        int32(cents%100) * 1e7,
+	}
+}
+
+// MoneyToFloat converts google.type.Money back into a decimal amount, nil is
+// treated as zero
+func MoneyToFloat(m *money.Money) float64 {
+	if m == nil {
+		return 0
+	}
+	return float64(m.Units) + float64(m.Nanos)/1e9
+}
+
+// MoneyToCents converts google.type.Money into whole cents, nil is treated as
+// zero
+func MoneyToCents(m *money.Money) int64 {
+	if m == nil {
+		return 0
+	}
+	return m.Units*100 + int64(math.Round(float64(m.Nanos)/1e7))
+}
@@ -0,0 +1,84 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	pb "null-statement-parser/internal/gen/null/v1"
+)
+
+const receiptPageSize = 100
+
+func (c *Client) UploadReceipt(userID string, imageData []byte, contentType string) (*pb.Receipt, error) {
+	ctx := c.withAuth(context.Background())
+	resp, err := c.receiptClient.UploadReceipt(ctx, &pb.UploadReceiptRequest{
+		UserId:      userID,
+		ImageData:   imageData,
+		ContentType: contentType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload receipt: %w", err)
+	}
+	c.log.Info("uploaded receipt", "receipt_id", resp.Receipt.GetId(), "status", resp.Receipt.GetStatus())
+	return resp.Receipt, nil
+}
+
+// ListReceipts pages through all receipts of a user, optionally restricted to
+// ones that are not linked to a transaction yet
+func (c *Client) ListReceipts(userID string, unlinkedOnly bool) ([]*pb.Receipt, error) {
+	ctx := c.withAuth(context.Background())
+
+	var receipts []*pb.Receipt
+	for offset := int32(0); ; offset += receiptPageSize {
+		req := &pb.ListReceiptsRequest{
+			UserId: userID,
+			Limit:  ptr(int32(receiptPageSize)),
+			Offset: ptr(offset),
+		}
+		if unlinkedOnly {
+			req.UnlinkedOnly = ptr(true)
+		}
+
+		resp, err := c.receiptClient.ListReceipts(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list receipts: %w", err)
+		}
+		receipts = append(receipts, resp.Receipts...)
+
+		if len(resp.Receipts) < receiptPageSize || int64(len(receipts)) >= resp.TotalCount {
+			break
+		}
+	}
+
+	c.log.Info("successfully fetched receipts", "count", len(receipts))
+	return receipts, nil
+}
+
+func (c *Client) GetReceipt(userID string, receiptID int64) (*pb.Receipt, []*pb.ReceiptLinkCandidate, error) {
+	ctx := c.withAuth(context.Background())
+	resp, err := c.receiptClient.GetReceipt(ctx, &pb.GetReceiptRequest{UserId: userID, Id: receiptID})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+	return resp.Receipt, resp.LinkCandidates, nil
+}
+
+// UpdateReceipt links a receipt to a transaction, or unlinks it when
+// transactionID is nil
+func (c *Client) UpdateReceipt(userID string, receiptID int64, transactionID *int64) (*pb.Receipt, error) {
+	ctx := c.withAuth(context.Background())
+	resp, err := c.receiptClient.UpdateReceipt(ctx, &pb.UpdateReceiptRequest{
+		UserId:        userID,
+		Id:            receiptID,
+		TransactionId: transactionID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update receipt: %w", err)
+	}
+	c.log.Info("updated receipt", "receipt_id", receiptID, "transaction_id", resp.Receipt.GetTransactionId())
+	return resp.Receipt, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,351 @@
+// Package config loads named profiles from the config file and layers
+// environment variables on top of them
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+)
+
+const appName = "null-statement-parser"
+
+const DefaultBatchSize = 1000
+
+// Profile holds everything needed to talk to one ariand instance as one user
+type Profile struct {
+	Server string `toml:"server"`
+	UserID string `toml:"user_id"`
+
+	// credentials, first non-empty wins: api_key, api_key_file, api_key_command
+	APIKey        string `toml:"api_key"`
+	APIKeyFile    string `toml:"api_key_file"`
+	APIKeyCommand string `toml:"api_key_command"`
+
+	PDFPath string `toml:"pdf_path"`
+	CSVPath string `toml:"csv_path"`
+	// ParserConfig is the PDF parser's rule file: categories and excludes
+	ParserConfig string `toml:"parser_config"`
+	// CSVProfile is a built-in CSV profile name or a profile file
+	CSVProfile string `toml:"csv_profile"`
+	// MT940AccountType is the account type of MT940 statements
+	MT940AccountType string `toml:"mt940_account_type"`
+
+	// AccountMapping maps statement accounts to ariand accounts without prompting
+	AccountMapping string `toml:"account_mapping"`
+	// Journal is the default export -accounts file
+	Journal string `toml:"journal"`
+
+	BatchSize int `toml:"batch_size"`
+	// Workers is the number of PDFs parsed at once, 0 picks a default
+	Workers int `toml:"workers"`
+
+	// PDFPasswords are tried in order on encrypted PDFs
+	PDFPasswords []PDFPassword `toml:"pdf_passwords"`
+
+	// IMAP is the mailbox the watch command polls for alert emails
+	IMAP IMAP `toml:"imap"`
+
+	// Name is the selected profile, empty when none was used
+	Name string `toml:"-"`
+}
+
+// PDFPassword is one way of opening encrypted statements. Match limits it to
+// files whose name matches a glob, e.g. "*visa*" for one account's
+// statements; empty matches every file.
+type PDFPassword struct {
+	Match    string `toml:"match"`
+	Password string `toml:"password"`
+	Command  string `toml:"command"`
+	// Hint is shown when prompting for the password, e.g. "date of birth,
+	// DDMMYYYY"
+	Hint string `toml:"hint"`
+}
+
+// IMAP describes a mailbox folder holding bank alert emails
+type IMAP struct {
+	// Server is host:port, the port defaults to 993 (143 without TLS)
+	Server   string `toml:"server"`
+	Username string `toml:"username"`
+
+	// first non-empty wins: password, password_file, password_command
+	Password        string `toml:"password"`
+	PasswordFile    string `toml:"password_file"`
+	PasswordCommand string `toml:"password_command"`
+
+	// Folder is polled for alerts, INBOX by default
+	Folder string `toml:"folder"`
+	// MoveTo is where imported alerts are moved, empty leaves them in place
+	MoveTo string `toml:"move_to"`
+	// Security is "tls" (default), "starttls" or "none". "none" sends the
+	// password in the clear and is meant for a local test server.
+	Security string `toml:"security"`
+	// InsecureSkipVerify accepts any server certificate, e.g. a self-signed
+	// one on a local test server
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// Interval between polls when the server has no IDLE, and the longest
+	// IDLE wait, e.g. "5m"
+	Interval string `toml:"interval"`
+}
+
+type file struct {
+	DefaultProfile string             `toml:"default_profile"`
+	Profiles       map[string]Profile `toml:"profiles"`
+}
+
+// Path returns the config file location: $NULL_CONFIG, else
+// $XDG_CONFIG_HOME/null-statement-parser/config.toml, else ~/.config/...
+func Path() (string, error) {
+	if path := os.Getenv("NULL_CONFIG"); path != "" {
+		return path, nil
+	}
+
+	base := os.Getenv("XDG_CONFIG_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		base = filepath.Join(home, ".config")
+	}
+	return filepath.Join(base, appName, "config.toml"), nil
+}
+
+// Load resolves the named profile (falling back to $NULL_PROFILE, then the
+// file's default_profile) and overrides it with environment variables. A
+// missing config file is only an error when a profile was asked for.
+func Load(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("NULL_PROFILE")
+	}
+
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg file
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		if name != "" {
+			return nil, fmt.Errorf("profile %q requested but %s does not exist", name, path)
+		}
+	}
+
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+
+	profile := &Profile{}
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in %s", name, path)
+		}
+		*profile = p
+		profile.Name = name
+		profile.expandPaths()
+	}
+
+	profile.applyEnv()
+	if profile.BatchSize <= 0 {
+		profile.BatchSize = DefaultBatchSize
+	}
+	return profile, nil
+}
+
+func (p *Profile) applyEnv() {
+	override := func(field *string, env string) {
+		if value := os.Getenv(env); value != "" {
+			*field = value
+		}
+	}
+	override(&p.Server, "NULL_CORE_URL")
+	override(&p.UserID, "USER_ID")
+	override(&p.PDFPath, "PDF_PATH")
+	override(&p.CSVPath, "CSV_PATH")
+
+	// PDF_PASSWORD is tried before the profile's passwords
+	if password := os.Getenv("PDF_PASSWORD"); password != "" {
+		p.PDFPasswords = append([]PDFPassword{{Password: password}}, p.PDFPasswords...)
+	}
+
+	// an API_KEY in the environment replaces whatever source the profile uses
+	if key := os.Getenv("API_KEY"); key != "" {
+		p.APIKey, p.APIKeyFile, p.APIKeyCommand = key, "", ""
+	}
+
+	override(&p.IMAP.Server, "IMAP_SERVER")
+	override(&p.IMAP.Username, "IMAP_USERNAME")
+	if password := os.Getenv("IMAP_PASSWORD"); password != "" {
+		p.IMAP.Password, p.IMAP.PasswordFile, p.IMAP.PasswordCommand = password, "", ""
+	}
+}
+
+// Credentials returns the user ID, server URL and API key, naming every
+// setting that is missing
+func (p *Profile) Credentials() (userID, serverURL, apiKey string, err error) {
+	apiKey, err = p.resolveAPIKey()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var missing []string
+	if p.UserID == "" {
+		missing = append(missing, "USER_ID (user_id)")
+	}
+	if p.Server == "" {
+		missing = append(missing, "NULL_CORE_URL (server)")
+	}
+	if apiKey == "" {
+		missing = append(missing, "API_KEY (api_key, api_key_file or api_key_command)")
+	}
+	if len(missing) > 0 {
+		return "", "", "", fmt.Errorf("need %s", strings.Join(missing, ", "))
+	}
+
+	return p.UserID, p.Server, apiKey, nil
+}
+
+func (p *Profile) resolveAPIKey() (string, error) {
+	return resolveSecret("api_key", p.APIKey, p.APIKeyFile, p.APIKeyCommand)
+}
+
+// IMAPCredentials returns the IMAP server, username and password, naming
+// every setting that is missing
+func (p *Profile) IMAPCredentials() (server, username, password string, err error) {
+	password, err = resolveSecret("imap.password", p.IMAP.Password, p.IMAP.PasswordFile, p.IMAP.PasswordCommand)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	var missing []string
+	if p.IMAP.Server == "" {
+		missing = append(missing, "IMAP_SERVER (imap.server)")
+	}
+	if p.IMAP.Username == "" {
+		missing = append(missing, "IMAP_USERNAME (imap.username)")
+	}
+	if password == "" {
+		missing = append(missing, "IMAP_PASSWORD (imap.password, imap.password_file or imap.password_command)")
+	}
+	if len(missing) > 0 {
+		return "", "", "", fmt.Errorf("need %s", strings.Join(missing, ", "))
+	}
+
+	return p.IMAP.Server, p.IMAP.Username, password, nil
+}
+
+// resolveSecret returns value, else the contents of file, else the output of
+// command, naming the settings after name in errors
+func resolveSecret(name, value, file, command string) (string, error) {
+	switch {
+	case value != "":
+		return value, nil
+	case file != "":
+		data, err := os.ReadFile(expandHome(file))
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_file: %w", name, err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case command != "":
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("%s_command failed: %w", name, err)
+		}
+		return strings.TrimSpace(string(out)), nil
+	default:
+		return "", nil
+	}
+}
+
+// PDFPasswordsFor returns the passwords to try on an encrypted file, running
+// password commands as needed, and the hints of the entries that match it
+func (p *Profile) PDFPasswordsFor(file string) (passwords, hints []string, err error) {
+	name := filepath.Base(file)
+	for _, entry := range p.PDFPasswords {
+		if entry.Match != "" {
+			matched, err := filepath.Match(strings.ToLower(entry.Match), strings.ToLower(name))
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid pdf_passwords match %q: %w", entry.Match, err)
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		if entry.Hint != "" {
+			hints = append(hints, entry.Hint)
+		}
+		if entry.Password != "" {
+			passwords = append(passwords, entry.Password)
+		}
+		if entry.Command != "" {
+			password, err := commandOutput(entry.Command)
+			if err != nil {
+				return nil, nil, fmt.Errorf("pdf password command failed: %w", err)
+			}
+			if password != "" {
+				passwords = append(passwords, password)
+			}
+		}
+	}
+	return passwords, hints, nil
+}
+
+// commandOutputs caches password command output, so `pass` and friends only
+// ask once per run even with several PDFs parsed at once
+var commandOutputs struct {
+	sync.Mutex
+	byCommand map[string]string
+}
+
+func commandOutput(command string) (string, error) {
+	commandOutputs.Lock()
+	defer commandOutputs.Unlock()
+
+	if output, ok := commandOutputs.byCommand[command]; ok {
+		return output, nil
+	}
+
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+
+	// `pass show` prints the password on the first line
+	output, _, _ := strings.Cut(string(out), "\n")
+	output = strings.TrimSpace(output)
+	if commandOutputs.byCommand == nil {
+		commandOutputs.byCommand = make(map[string]string)
+	}
+	commandOutputs.byCommand[command] = output
+	return output, nil
+}
+
+func (p *Profile) expandPaths() {
+	for _, field := range []*string{&p.PDFPath, &p.CSVPath, &p.ParserConfig, &p.AccountMapping, &p.Journal} {
+		*field = expandHome(*field)
+	}
+}
+
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
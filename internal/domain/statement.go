@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// Statement is the statement-level information of a parsed source file. The
+// balances are as printed, so for credit cards they are the amount owed. For
+// investment accounts they are the cash balance, and AccountValue is the cash
+// plus holdings at market value on PeriodEnd.
+type Statement struct {
+	SourceFilePath string    `json:"source_file_path"`
+	AccountNumber  string    `json:"account_number,omitempty"`
+	AccountType    string    `json:"account_type"`
+	AccountName    string    `json:"account_name,omitempty"`
+	Currency       string    `json:"currency,omitempty"`
+	PeriodStart    time.Time `json:"period_start,omitzero"`
+	PeriodEnd      time.Time `json:"period_end,omitzero"`
+	OpeningBalance *float64  `json:"opening_balance,omitempty"`
+	ClosingBalance *float64  `json:"closing_balance,omitempty"`
+	AccountValue   *float64  `json:"account_value,omitempty"`
+}
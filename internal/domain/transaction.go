@@ -1,6 +1,11 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Direction int
 
@@ -9,19 +14,222 @@ const (
 	Out
 )
 
+func (d Direction) String() string {
+	if d == Out {
+		return "out"
+	}
+	return "in"
+}
+
+func (d Direction) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Direction) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "in":
+		*d = In
+	case "out":
+		*d = Out
+	default:
+		return fmt.Errorf("invalid direction: %q", text)
+	}
+	return nil
+}
+
+// DateSource selects which statement date is sent to ariand as TxDate
+type DateSource string
+
+const (
+	TransactionDate DateSource = "transaction"
+	PostingDate     DateSource = "posting"
+)
+
+func ParseDateSource(s string) (DateSource, error) {
+	switch source := DateSource(strings.ToLower(s)); source {
+	case TransactionDate, PostingDate:
+		return source, nil
+	}
+	return "", fmt.Errorf("invalid date source: %q (want transaction or posting)", s)
+}
+
 type Transaction struct {
-	AccountID   int
-	EmailID     string
-	TxDate      time.Time
-	TxAmount    float64
-	TxCurrency  string
-	TxDirection Direction
-	TxDesc      string
-	Merchant    string
-	UserNotes   string
+	AccountID int `json:"account_id,omitempty"`
+	// EmailID is the Message-ID of the alert email the transaction came from
+	EmailID     string    `json:"email_id,omitempty"`
+	TxDate      time.Time `json:"tx_date"`
+	PostingDate time.Time `json:"posting_date,omitzero"` // zero if the source has a single date
+	TxAmount    float64   `json:"tx_amount"`
+	TxCurrency  string    `json:"tx_currency"`
+	TxDirection Direction `json:"tx_direction"`
+	TxDesc      string    `json:"tx_desc"`
+	Merchant    string    `json:"merchant,omitempty"`
+	UserNotes   string    `json:"user_notes,omitempty"`
+	Category    string    `json:"category,omitempty"`
+	// ExternalID is the bank's own reference for the line, e.g. the Visa
+	// 23-digit reference code
+	ExternalID string `json:"external_id,omitempty"`
+	// Source is the kind of input the transaction was parsed from: pdf, csv,
+	// email, ...
+	Source string `json:"source,omitempty"`
 	// Account matching info from statement
-	StatementAccountNumber *string
-	StatementAccountType   string
-	StatementAccountName   string
-	SourceFilePath         string
+	StatementAccountNumber *string `json:"statement_account_number,omitempty"`
+	StatementAccountType   string  `json:"statement_account_type"`
+	StatementAccountName   string  `json:"statement_account_name,omitempty"`
+	// StatementBank is the bank the source belongs to, empty when the format
+	// doesn't say
+	StatementBank  string `json:"statement_bank,omitempty"`
+	SourceFilePath string `json:"source_file_path,omitempty"`
+}
+
+// Date returns the posting date when requested and known, otherwise the
+// transaction date
+func (tx *Transaction) Date(source DateSource) time.Time {
+	if source == PostingDate && !tx.PostingDate.IsZero() {
+		return tx.PostingDate
+	}
+	return tx.TxDate
+}
+
+// DedupKey identifies a transaction for duplicate detection. The bank's
+// reference or the alert email's Message-ID is used where one exists, since
+// two identical purchases on the same day are otherwise indistinguishable.
+// References are only unique within an account, so every key is scoped to the
+// account number and type.
+func (tx *Transaction) DedupKey() string {
+	account := ""
+	if tx.StatementAccountNumber != nil {
+		account = *tx.StatementAccountNumber
+	}
+	scope := account + "|" + tx.StatementAccountType + "|"
+
+	if tx.ExternalID != "" {
+		return scope + "ref:" + tx.ExternalID
+	}
+	if tx.EmailID != "" {
+		return scope + emailTagPrefix + tx.EmailID
+	}
+
+	return scope + strings.Join([]string{
+		tx.TxDate.Format(time.DateOnly),
+		strconv.FormatFloat(tx.TxAmount, 'f', 2, 64),
+		tx.TxDirection.String(),
+		strings.ToLower(strings.TrimSpace(tx.TxDesc)),
+	}, "|")
+}
+
+// DefaultCurrency is assumed when a source doesn't state one
+const DefaultCurrency = "CAD"
+
+const (
+	SourcePDF   = "pdf"
+	SourceCSV   = "csv"
+	SourceQIF   = "qif"
+	SourceCAMT  = "camt"
+	SourceMT940 = "mt940"
+	SourceEmail = "email"
+)
+
+// provisionalSources produce rows that are replaced once the official
+// statement arrives
+var provisionalSources = map[string]bool{
+	SourceCSV:   true,
+	SourceEmail: true,
+}
+
+const (
+	sourceTagPrefix = "source: "
+	emailTagPrefix  = "email: "
+	refTagPrefix    = "ref: "
+)
+
+// IsProvisional reports whether the transaction comes from a source that is
+// later superseded by the PDF statement
+func (tx *Transaction) IsProvisional() bool {
+	return provisionalSources[tx.Source]
+}
+
+// UploadNotes returns the user notes with the statement reference appended,
+// so a disputed charge can be traced back to its statement line, and the
+// alert email's Message-ID, so the same email is not imported twice.
+// Provisional rows are also tagged with their source so reconciliation can
+// find them.
+func (tx *Transaction) UploadNotes() string {
+	notes := tx.UserNotes
+	if tx.ExternalID != "" {
+		notes = appendNoteLine(notes, refTagPrefix+tx.ExternalID)
+	}
+	if tx.EmailID != "" {
+		notes = appendNoteLine(notes, emailTagPrefix+tx.EmailID)
+	}
+	if tx.IsProvisional() {
+		notes = appendNoteLine(notes, sourceTagPrefix+tx.Source)
+	}
+	return notes
+}
+
+// HasProvisionalTag reports whether uploaded notes mark the transaction as
+// coming from a provisional source
+func HasProvisionalTag(notes string) bool {
+	for _, line := range strings.Split(notes, "\n") {
+		if source, ok := strings.CutPrefix(line, sourceTagPrefix); ok && provisionalSources[source] {
+			return true
+		}
+	}
+	return false
+}
+
+// HasReference reports whether uploaded notes carry a statement reference,
+// which only statement lines have
+func HasReference(notes string) bool {
+	for _, line := range strings.Split(notes, "\n") {
+		if strings.HasPrefix(line, refTagPrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reference returns the statement reference recorded in uploaded notes, or
+// an empty string when there is none
+func Reference(notes string) string {
+	for _, line := range strings.Split(notes, "\n") {
+		if ref, ok := strings.CutPrefix(line, refTagPrefix); ok {
+			return strings.TrimSpace(ref)
+		}
+	}
+	return ""
+}
+
+// EmailIDs returns the alert email Message-IDs recorded in uploaded notes
+func EmailIDs(notes string) []string {
+	var ids []string
+	for _, line := range strings.Split(notes, "\n") {
+		if id, ok := strings.CutPrefix(line, emailTagPrefix); ok {
+			ids = append(ids, strings.TrimSpace(id))
+		}
+	}
+	return ids
+}
+
+// StripProvisionalTag removes the source tag added by UploadNotes
+func StripProvisionalTag(notes string) string {
+	var kept []string
+	for _, line := range strings.Split(notes, "\n") {
+		if source, ok := strings.CutPrefix(line, sourceTagPrefix); ok && provisionalSources[source] {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+func appendNoteLine(notes, line string) string {
+	if notes == "" {
+		return line
+	}
+	if strings.Contains(notes, line) {
+		return notes
+	}
+	return notes + "\n" + line
 }
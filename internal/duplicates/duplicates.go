@@ -0,0 +1,248 @@
+// Package duplicates finds ariand accounts that are likely the same real
+// account, such as one an import created under the full number, another
+// under the last 4 digits and a third made by hand in the UI
+package duplicates
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/alias"
+	"null-statement-parser/internal/client"
+	pb "null-statement-parser/internal/gen/null/v1"
+)
+
+type Options struct {
+	// MinOverlap is the share of the smaller account's transactions that
+	// must also be in the other account for the histories to count as the
+	// same
+	MinOverlap float64
+	// MinShared is the fewest shared transactions that count, so two
+	// accounts charged the same fee on the same day are not taken for one
+	MinShared int
+}
+
+func DefaultOptions() Options {
+	return Options{MinOverlap: 0.5, MinShared: 3}
+}
+
+// Account is an ariand account with a summary of its transactions
+type Account struct {
+	*pb.Account
+	Transactions int
+	First, Last  time.Time
+
+	identifiers  map[string]bool
+	fingerprints map[string]bool
+}
+
+func NewAccount(account *pb.Account, transactions []*pb.Transaction) *Account {
+	a := &Account{
+		Account:      account,
+		Transactions: len(transactions),
+		identifiers:  make(map[string]bool),
+		fingerprints: make(map[string]bool, len(transactions)),
+	}
+
+	// importers name accounts after the number when nothing better is known
+	names := append([]string{account.GetName()}, account.GetAliases()...)
+	for _, name := range names {
+		if len(alias.Digits(name)) < 4 && !slices.Contains(account.GetAliases(), name) {
+			continue
+		}
+		for _, variant := range alias.Variants(name) {
+			// masked forms say no more than the last 4 digits
+			if !strings.Contains(variant, "*") {
+				a.identifiers[strings.ToLower(variant)] = true
+			}
+		}
+	}
+
+	for _, tx := range transactions {
+		date := tx.GetTxDate().AsTime()
+		if a.First.IsZero() || date.Before(a.First) {
+			a.First = date
+		}
+		if date.After(a.Last) {
+			a.Last = date
+		}
+		a.fingerprints[fingerprint(tx)] = true
+	}
+	return a
+}
+
+func fingerprint(tx *pb.Transaction) string {
+	cents := int64(math.Round(client.MoneyToFloat(tx.GetTxAmount()) * 100))
+	return fmt.Sprintf("%s|%d|%s", tx.GetTxDate().AsTime().Format(time.DateOnly), cents, tx.GetDirection())
+}
+
+// Group is a set of accounts that look like one, with why
+type Group struct {
+	Accounts []*Account
+	Reasons  []string
+}
+
+// Primary suggests the account to keep: the one with the most transactions,
+// else the oldest
+func (g Group) Primary() *Account {
+	primary := g.Accounts[0]
+	for _, a := range g.Accounts[1:] {
+		if a.Transactions > primary.Transactions || (a.Transactions == primary.Transactions && a.Id < primary.Id) {
+			primary = a
+		}
+	}
+	return primary
+}
+
+// Find groups accounts that share an account number in their names or
+// aliases, or whose transaction histories overlap. An account only joins a
+// group when its type and bank fit every member already in it, so a chequing
+// account and a card are not joined through an account with no type.
+func Find(accounts []*Account, opts Options) []Group {
+	parent := make([]int, len(accounts))
+	for i := range parent {
+		parent[i] = i
+	}
+	var root func(int) int
+	root = func(i int) int {
+		for parent[i] != i {
+			parent[i] = parent[parent[i]]
+			i = parent[i]
+		}
+		return i
+	}
+
+	reasons := make(map[int][]string)
+	for i := range accounts {
+		for j := i + 1; j < len(accounts); j++ {
+			why := match(accounts[i], accounts[j], opts)
+			if len(why) == 0 {
+				continue
+			}
+			ri, rj := root(i), root(j)
+			if ri != rj {
+				if !fits(accounts, root, ri, rj) {
+					continue
+				}
+				parent[rj] = ri
+				reasons[ri] = append(reasons[ri], reasons[rj]...)
+				delete(reasons, rj)
+			}
+			reasons[ri] = append(reasons[ri], why...)
+		}
+	}
+
+	members := make(map[int][]*Account)
+	for i, a := range accounts {
+		r := root(i)
+		members[r] = append(members[r], a)
+	}
+
+	var groups []Group
+	for r, group := range members {
+		if len(group) < 2 {
+			continue
+		}
+		groups = append(groups, Group{Accounts: group, Reasons: uniqueStrings(reasons[r])})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Accounts[0].Id < groups[j].Accounts[0].Id })
+	return groups
+}
+
+// fits reports whether every account in group a is compatible with every
+// account in group b
+func fits(accounts []*Account, root func(int) int, a, b int) bool {
+	for i := range accounts {
+		if root(i) != a {
+			continue
+		}
+		for j := range accounts {
+			if root(j) == b && !compatible(accounts[i], accounts[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// match returns why two accounts look like one, nothing when they don't
+func match(a, b *Account, opts Options) []string {
+	if !compatible(a, b) {
+		return nil
+	}
+
+	var why []string
+	if shared := sharedIdentifier(a, b); shared != "" {
+		why = append(why, fmt.Sprintf("'%s' and '%s' share number %s", a.Name, b.Name, shared))
+	}
+	if overlap, shared := historyOverlap(a, b); shared >= opts.MinShared && overlap >= opts.MinOverlap {
+		why = append(why, fmt.Sprintf("'%s' and '%s' share %.0f%% of transactions", a.Name, b.Name, overlap*100))
+	}
+	if len(why) > 0 && a.Type == b.Type && strings.EqualFold(a.Bank, b.Bank) && a.Bank != "" {
+		why = append(why, fmt.Sprintf("same bank and type (%s %s)", a.Bank, a.Type))
+	}
+	return why
+}
+
+// compatible reports whether two accounts could be one: the same or a loose
+// type, and the same bank when both name one
+func compatible(a, b *Account) bool {
+	if !compatibleType(a.Type, b.Type) {
+		return false
+	}
+	return a.Bank == "" || b.Bank == "" || strings.EqualFold(a.Bank, b.Bank)
+}
+
+func compatibleType(a, b pb.AccountType) bool {
+	loose := func(t pb.AccountType) bool {
+		return t == pb.AccountType_ACCOUNT_UNSPECIFIED || t == pb.AccountType_ACCOUNT_OTHER
+	}
+	return a == b || loose(a) || loose(b)
+}
+
+// sharedIdentifier returns the most specific number both accounts go by
+func sharedIdentifier(a, b *Account) string {
+	var best string
+	for id := range a.identifiers {
+		if b.identifiers[id] && (len(id) > len(best) || (len(id) == len(best) && id < best)) {
+			best = id
+		}
+	}
+	return best
+}
+
+// historyOverlap returns the share of the smaller history found in the other,
+// and how many transactions that is
+func historyOverlap(a, b *Account) (float64, int) {
+	small, large := a.fingerprints, b.fingerprints
+	if len(small) > len(large) {
+		small, large = large, small
+	}
+	if len(small) == 0 {
+		return 0, 0
+	}
+
+	shared := 0
+	for fp := range small {
+		if large[fp] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(small)), shared
+}
+
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,70 @@
+package duplicates
+
+import (
+	"testing"
+
+	pb "null-statement-parser/internal/gen/null/v1"
+)
+
+func TestFind(t *testing.T) {
+	account := func(id int64, name string, accountType pb.AccountType, bank string) *Account {
+		return NewAccount(&pb.Account{Id: id, Name: name, Type: accountType, Bank: bank}, nil)
+	}
+
+	tests := []struct {
+		name     string
+		accounts []*Account
+		want     [][]int64
+	}{
+		{
+			name: "full number and last 4",
+			accounts: []*Account{
+				account(1, "051725163878", pb.AccountType_ACCOUNT_CHEQUING, "RBC"),
+				account(2, "3878", pb.AccountType_ACCOUNT_CHEQUING, ""),
+			},
+			want: [][]int64{{1, 2}},
+		},
+		{
+			name: "different banks",
+			accounts: []*Account{
+				account(1, "4510123412341234", pb.AccountType_ACCOUNT_CREDIT_CARD, "RBC"),
+				account(2, "4500123412341234", pb.AccountType_ACCOUNT_CREDIT_CARD, "TD"),
+			},
+		},
+		{
+			// the untyped account matches both, but they don't match each other
+			name: "chequing and card through an untyped account",
+			accounts: []*Account{
+				account(1, "051725161234", pb.AccountType_ACCOUNT_CHEQUING, ""),
+				account(2, "1234", pb.AccountType_ACCOUNT_UNSPECIFIED, ""),
+				account(3, "4510123412341234", pb.AccountType_ACCOUNT_CREDIT_CARD, ""),
+			},
+			want: [][]int64{{1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			groups := Find(tt.accounts, DefaultOptions())
+			if len(groups) != len(tt.want) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(tt.want))
+			}
+			for i, group := range groups {
+				var ids []int64
+				for _, a := range group.Accounts {
+					ids = append(ids, a.Id)
+				}
+				if len(ids) != len(tt.want[i]) {
+					t.Errorf("group %d: got accounts %v, want %v", i, ids, tt.want[i])
+					continue
+				}
+				for k := range ids {
+					if ids[k] != tt.want[i][k] {
+						t.Errorf("group %d: got accounts %v, want %v", i, ids, tt.want[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
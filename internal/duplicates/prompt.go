@@ -0,0 +1,54 @@
+package duplicates
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	OptionSkip = "__skip__"
+)
+
+// PromptForPrimary asks which account of a group to keep, the rest are merged
+// into it. It returns 0 if the group was skipped.
+func PromptForPrimary(g Group) (int64, error) {
+	selectedOption := strconv.FormatInt(g.Primary().Id, 10)
+
+	options := make([]huh.Option[string], 0, len(g.Accounts)+1)
+	for _, a := range g.Accounts {
+		options = append(options, huh.NewOption(Describe(a), strconv.FormatInt(a.Id, 10)))
+	}
+	options = append(options, huh.NewOption("Skip, these are different accounts", OptionSkip))
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(fmt.Sprintf("%d accounts look like one", len(g.Accounts))).
+				Description("Keep and merge the others into:").
+				Options(options...).
+				Value(&selectedOption),
+		),
+	).WithOutput(os.Stderr)
+
+	if err := form.Run(); err != nil {
+		return 0, fmt.Errorf("prompt failed: %w", err)
+	}
+
+	if selectedOption == OptionSkip {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(selectedOption, 10, 64)
+}
+
+// Describe summarises an account on one line
+func Describe(a *Account) string {
+	history := "no transactions"
+	if a.Transactions > 0 {
+		history = fmt.Sprintf("%d transactions, %s to %s", a.Transactions, a.First.Format("2006-01-02"), a.Last.Format("2006-01-02"))
+	}
+	return fmt.Sprintf("#%d %s (%s - %s), %s", a.Id, a.Name, a.Bank, a.Type.String(), history)
+}
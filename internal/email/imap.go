@@ -0,0 +1,240 @@
+package email
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+)
+
+// IMAPConfig says how to reach the mailbox folder alerts arrive in
+type IMAPConfig struct {
+	// Server is host:port, the port defaults to 993, or 143 without TLS
+	Server   string
+	Username string
+	Password string
+	Folder   string
+	// Security is "tls", "starttls" or "none"
+	Security           string
+	InsecureSkipVerify bool
+}
+
+// Checkpoint is the last message read from a folder. UIDs are only
+// comparable while the folder's UIDVALIDITY stays the same.
+type Checkpoint struct {
+	UIDValidity uint32 `json:"uid_validity"`
+	LastUID     uint32 `json:"last_uid"`
+}
+
+// LoadCheckpoint reads a checkpoint saved by Save, returning the zero
+// checkpoint, which reads the whole folder, when there is none yet
+func LoadCheckpoint(path string) (Checkpoint, error) {
+	var cp Checkpoint
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	}
+	if err != nil {
+		return cp, fmt.Errorf("failed to read IMAP checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return cp, fmt.Errorf("failed to parse IMAP checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+func (cp Checkpoint) Save(path string) error {
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode IMAP checkpoint: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write IMAP checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Mailbox is a logged in connection with the alert folder selected
+type Mailbox struct {
+	config      IMAPConfig
+	client      *imapclient.Client
+	uidValidity uint32
+	// updates is signalled when the server reports new messages
+	updates chan struct{}
+}
+
+// DialIMAP connects, logs in and selects the configured folder
+func DialIMAP(config IMAPConfig) (*Mailbox, error) {
+	if config.Folder == "" {
+		config.Folder = "INBOX"
+	}
+	if config.Security == "" {
+		config.Security = "tls"
+	}
+
+	address := config.Server
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		port := "993"
+		if config.Security != "tls" {
+			port = "143"
+		}
+		address = net.JoinHostPort(address, port)
+	}
+
+	m := &Mailbox{config: config, updates: make(chan struct{}, 1)}
+	options := &imapclient.Options{
+		TLSConfig: &tls.Config{InsecureSkipVerify: config.InsecureSkipVerify},
+		UnilateralDataHandler: &imapclient.UnilateralDataHandler{
+			Mailbox: func(data *imapclient.UnilateralDataMailbox) {
+				if data.NumMessages != nil {
+					m.notify()
+				}
+			},
+		},
+	}
+
+	var err error
+	switch config.Security {
+	case "tls":
+		m.client, err = imapclient.DialTLS(address, options)
+	case "starttls":
+		m.client, err = imapclient.DialStartTLS(address, options)
+	case "none":
+		m.client, err = imapclient.DialInsecure(address, options)
+	default:
+		return nil, fmt.Errorf("unknown IMAP security %q (want tls, starttls or none)", config.Security)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", address, err)
+	}
+
+	if err := m.client.Login(config.Username, config.Password).Wait(); err != nil {
+		m.client.Close()
+		return nil, fmt.Errorf("IMAP login failed: %w", err)
+	}
+
+	selected, err := m.client.Select(config.Folder, nil).Wait()
+	if err != nil {
+		m.Close()
+		return nil, fmt.Errorf("failed to select %s: %w", config.Folder, err)
+	}
+	m.uidValidity = selected.UIDValidity
+
+	return m, nil
+}
+
+func (m *Mailbox) notify() {
+	select {
+	case m.updates <- struct{}{}:
+	default:
+	}
+}
+
+// Close logs out and closes the connection
+func (m *Mailbox) Close() error {
+	m.client.Logout().Wait()
+	return m.client.Close()
+}
+
+// Fetch returns the messages that arrived after cp, and the checkpoint to
+// save once they are dealt with. When the folder's UIDVALIDITY changed, the
+// old UIDs mean nothing and the whole folder is read again.
+func (m *Mailbox) Fetch(cp Checkpoint) ([]Message, Checkpoint, error) {
+	next := Checkpoint{UIDValidity: m.uidValidity}
+	if cp.UIDValidity == m.uidValidity {
+		next.LastUID = cp.LastUID
+	}
+
+	var uids imap.UIDSet
+	uids.AddRange(imap.UID(next.LastUID+1), 0)
+	fetched, err := m.client.Fetch(uids, &imap.FetchOptions{
+		UID:         true,
+		BodySection: []*imap.FetchItemBodySection{{Peek: true}},
+	}).Collect()
+	if err != nil {
+		return nil, cp, fmt.Errorf("failed to fetch from %s: %w", m.config.Folder, err)
+	}
+
+	var messages []Message
+	for _, buf := range fetched {
+		// "n:*" always includes the newest message, even below n
+		uid := uint32(buf.UID)
+		if uid <= next.LastUID || len(buf.BodySection) == 0 {
+			continue
+		}
+		messages = append(messages, Message{
+			Location: fmt.Sprintf("imap://%s/%s;UID=%d", m.config.Server, m.config.Folder, uid),
+			Raw:      buf.BodySection[0].Bytes,
+			UID:      uid,
+		})
+	}
+	for _, msg := range messages {
+		next.LastUID = max(next.LastUID, msg.UID)
+	}
+
+	return messages, next, nil
+}
+
+// Move moves messages to another folder, creating it on first use
+func (m *Mailbox) Move(uids []uint32, folder string) error {
+	if len(uids) == 0 {
+		return nil
+	}
+
+	var set imap.UIDSet
+	for _, uid := range uids {
+		set.AddNum(imap.UID(uid))
+	}
+
+	if _, err := m.client.Move(set, folder).Wait(); err != nil {
+		// servers answer [TRYCREATE] when the target doesn't exist
+		if createErr := m.client.Create(folder, nil).Wait(); createErr != nil {
+			return fmt.Errorf("failed to move messages to %s: %w", folder, err)
+		}
+		if _, err := m.client.Move(set, folder).Wait(); err != nil {
+			return fmt.Errorf("failed to move messages to %s: %w", folder, err)
+		}
+	}
+	return nil
+}
+
+// Wait blocks until new messages arrive or timeout passes. Servers with IDLE
+// push new messages as they arrive; without it this only sleeps, and the
+// next Fetch finds what came in meanwhile.
+func (m *Mailbox) Wait(timeout time.Duration) error {
+	caps := m.client.Caps()
+	if !caps.Has(imap.CapIdle) && !caps.Has(imap.CapIMAP4rev2) {
+		select {
+		case <-time.After(timeout):
+			return nil
+		case <-m.client.Closed():
+			return fmt.Errorf("IMAP connection closed")
+		}
+	}
+
+	idle, err := m.client.Idle()
+	if err != nil {
+		return fmt.Errorf("IMAP IDLE failed: %w", err)
+	}
+
+	select {
+	case <-m.updates:
+	case <-time.After(timeout):
+	case <-m.client.Closed():
+		return fmt.Errorf("IMAP connection closed")
+	}
+
+	if err := idle.Close(); err != nil {
+		return fmt.Errorf("IMAP IDLE failed: %w", err)
+	}
+	if err := idle.Wait(); err != nil {
+		return fmt.Errorf("IMAP IDLE failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapserver"
+	"github.com/emersion/go-imap/v2/imapserver/imapmemserver"
+)
+
+// testIMAPServer starts an in-memory IMAP server with one user and an
+// "Alerts" folder, returning the user and the config to reach it
+func testIMAPServer(t *testing.T) (*imapmemserver.User, IMAPConfig) {
+	t.Helper()
+
+	memServer := imapmemserver.New()
+	user := imapmemserver.NewUser("alerts@example.com", "secret")
+	if err := user.Create("Alerts", nil); err != nil {
+		t.Fatal(err)
+	}
+	memServer.AddUser(user)
+
+	server := imapserver.New(&imapserver.Options{
+		NewSession: func(*imapserver.Conn) (imapserver.Session, *imapserver.GreetingData, error) {
+			return memServer.NewSession(), nil, nil
+		},
+		Caps:         imap.CapSet{imap.CapIMAP4rev1: {}, imap.CapIMAP4rev2: {}},
+		InsecureAuth: true,
+	})
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	return user, IMAPConfig{
+		Server:   listener.Addr().String(),
+		Username: "alerts@example.com",
+		Password: "secret",
+		Folder:   "Alerts",
+		Security: "none",
+	}
+}
+
+func appendMessage(t *testing.T, user *imapmemserver.User, folder, subject string) {
+	t.Helper()
+	raw := fmt.Sprintf("From: alerts@rbc.com\r\nSubject: %s\r\n\r\nbody\r\n", subject)
+	if _, err := user.Append(folder, bytes.NewReader([]byte(raw)), &imap.AppendOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func dial(t *testing.T, config IMAPConfig) *Mailbox {
+	t.Helper()
+	mailbox, err := DialIMAP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { mailbox.Close() })
+	return mailbox
+}
+
+func TestFetchReadsSinceCheckpoint(t *testing.T) {
+	user, config := testIMAPServer(t)
+	appendMessage(t, user, "Alerts", "one")
+	appendMessage(t, user, "Alerts", "two")
+
+	mailbox := dial(t, config)
+	messages, next, err := mailbox.Fetch(Checkpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || next.LastUID != 2 || next.UIDValidity == 0 {
+		t.Fatalf("got %d messages, checkpoint %+v", len(messages), next)
+	}
+
+	// "n:*" returns the newest message even when it is below n
+	messages, again, err := mailbox.Fetch(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 0 || again != next {
+		t.Fatalf("got %d messages, checkpoint %+v, want none and %+v", len(messages), again, next)
+	}
+
+	appendMessage(t, user, "Alerts", "three")
+	mailbox = dial(t, config)
+	messages, next, err = mailbox.Fetch(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].UID != 3 || next.LastUID != 3 {
+		t.Fatalf("got %d messages, checkpoint %+v", len(messages), next)
+	}
+	if !bytes.Contains(messages[0].Raw, []byte("Subject: three")) {
+		t.Fatalf("got message %q", messages[0].Raw)
+	}
+}
+
+func TestFetchRereadsFolderWhenUIDValidityChanges(t *testing.T) {
+	user, config := testIMAPServer(t)
+	appendMessage(t, user, "Alerts", "one")
+
+	_, old, err := dial(t, config).Fetch(Checkpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a folder deleted and created again gets a new UIDVALIDITY
+	if err := user.Delete("Alerts"); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Create("Alerts", nil); err != nil {
+		t.Fatal(err)
+	}
+	appendMessage(t, user, "Alerts", "two")
+	appendMessage(t, user, "Alerts", "three")
+
+	messages, next, err := dial(t, config).Fetch(Checkpoint{UIDValidity: old.UIDValidity, LastUID: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want the whole folder", len(messages))
+	}
+	if next.UIDValidity == old.UIDValidity || next.LastUID != 2 {
+		t.Fatalf("got checkpoint %+v after %+v", next, old)
+	}
+}
+
+func TestMoveCreatesFolder(t *testing.T) {
+	user, config := testIMAPServer(t)
+	appendMessage(t, user, "Alerts", "one")
+	appendMessage(t, user, "Alerts", "two")
+
+	mailbox := dial(t, config)
+	if err := mailbox.Move([]uint32{1}, "Imported"); err != nil {
+		t.Fatal(err)
+	}
+
+	messages, _, err := mailbox.Fetch(Checkpoint{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].UID != 2 {
+		t.Fatalf("got %d messages left in Alerts, want UID 2", len(messages))
+	}
+
+	status, err := user.Status("Imported", &imap.StatusOptions{NumMessages: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.NumMessages == nil || *status.NumMessages != 1 {
+		t.Fatalf("got %v messages in Imported, want 1", status.NumMessages)
+	}
+}
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	path := t.TempDir() + "/checkpoint.json"
+
+	cp, err := LoadCheckpoint(path)
+	if err != nil || cp != (Checkpoint{}) {
+		t.Fatalf("got %+v, %v before the first save", cp, err)
+	}
+
+	want := Checkpoint{UIDValidity: 7, LastUID: 42}
+	if err := want.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if cp, err = LoadCheckpoint(path); err != nil || cp != want {
+		t.Fatalf("got %+v, %v, want %+v", cp, err, want)
+	}
+}
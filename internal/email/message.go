@@ -0,0 +1,217 @@
+// Package email turns bank alert emails into transactions, so spending shows
+// up the day it happens instead of when the statement arrives
+package email
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+
+	"golang.org/x/text/encoding/htmlindex"
+)
+
+var (
+	htmlBreak = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</tr>|</td>`)
+	htmlTag   = regexp.MustCompile(`(?s)<!--.*?-->|<style.*?</style>|<script.*?</script>|<[^>]+>`)
+)
+
+// Message is one email read from a file, mbox, Maildir or IMAP folder
+type Message struct {
+	// Location names where the message came from, for error messages
+	Location string
+	Raw      []byte
+	// UID is the IMAP UID, 0 for messages read from disk
+	UID uint32
+}
+
+// ParseMessage returns the transaction an alert email describes, or nil when
+// the message is not a recognised alert
+func ParseMessage(msg Message) (*domain.Transaction, error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", msg.Location, err)
+	}
+
+	if !fromBank(parsed.Header.Get("From")) {
+		return nil, nil
+	}
+
+	body, err := messageText(parsed.Header, parsed.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", msg.Location, err)
+	}
+
+	sent, _ := parsed.Header.Date()
+	tx, err := matchAlert(body, sent)
+	if err != nil || tx == nil {
+		if err != nil {
+			err = fmt.Errorf("%s: %w", msg.Location, err)
+		}
+		return nil, err
+	}
+
+	tx.EmailID = strings.Trim(strings.TrimSpace(parsed.Header.Get("Message-ID")), "<>")
+	tx.SourceFilePath = msg.Location
+	return tx, nil
+}
+
+// fromBank checks the sender's domain, so a forwarded or phishing message
+// quoting an alert is not imported
+func fromBank(from string) bool {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return false
+	}
+	_, host, _ := strings.Cut(strings.ToLower(addr.Address), "@")
+	for _, sender := range alertSenders {
+		if host == sender || strings.HasSuffix(host, "."+sender) {
+			return true
+		}
+	}
+	return false
+}
+
+// messageText returns the message body as plain text with whitespace
+// collapsed, preferring a text/plain part over HTML
+func messageText(header mail.Header, body io.Reader) (string, error) {
+	text, htmlText, err := readPart(header.Get("Content-Type"), header.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		text = stripHTML(htmlText)
+	}
+	return strings.Join(strings.Fields(text), " "), nil
+}
+
+// readPart returns the first text/plain and text/html content found in a
+// part, descending into multipart containers
+func readPart(contentType, encoding string, body io.Reader) (text, htmlText string, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", "", fmt.Errorf("failed to read multipart body: %w", err)
+			}
+			partText, partHTML, err := readPart(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", "", err
+			}
+			if text == "" {
+				text = partText
+			}
+			if htmlText == "" {
+				htmlText = partHTML
+			}
+		}
+		return text, htmlText, nil
+	}
+
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+
+	content, err := decodeBody(encoding, params["charset"], body)
+	if err != nil {
+		return "", "", err
+	}
+	if mediaType == "text/html" {
+		return "", content, nil
+	}
+	return content, "", nil
+}
+
+func decodeBody(encoding, charset string, body io.Reader) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, newlineStripper{body})
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode body: %w", err)
+	}
+
+	if charset != "" && !strings.EqualFold(charset, "utf-8") && !strings.EqualFold(charset, "us-ascii") {
+		enc, err := htmlindex.Get(charset)
+		if err != nil {
+			return "", fmt.Errorf("unknown charset %q: %w", charset, err)
+		}
+		if data, err = enc.NewDecoder().Bytes(data); err != nil {
+			return "", fmt.Errorf("failed to decode %s: %w", charset, err)
+		}
+	}
+	return string(data), nil
+}
+
+// newlineStripper drops the line breaks base64 bodies are wrapped with
+type newlineStripper struct {
+	r io.Reader
+}
+
+func (s newlineStripper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+func stripHTML(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, " ")
+	return html.UnescapeString(s)
+}
+
+// alertTime combines the date and time printed in an alert, falling back to
+// when the message was sent. The wall clock time is kept as UTC, like the
+// dates of every other source.
+func alertTime(date, clock string, sent time.Time) (time.Time, error) {
+	if date == "" {
+		if sent.IsZero() {
+			return time.Time{}, fmt.Errorf("alert has no date")
+		}
+		y, m, d := sent.Date()
+		return time.Date(y, m, d, sent.Hour(), sent.Minute(), sent.Second(), 0, time.UTC), nil
+	}
+
+	// "Jan. 15, 2025 at 10:23 a.m."
+	value := strings.ReplaceAll(date, ".", "")
+	layouts := alertDateLayouts
+	if clock != "" {
+		value += " " + strings.ToUpper(strings.NewReplacer(" ", "", ".", "").Replace(clock))
+		layouts = alertDateTimeLayouts
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid alert date: %s", value)
+}
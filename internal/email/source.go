@@ -0,0 +1,151 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"null-statement-parser/internal/domain"
+)
+
+// ReadPath reads the messages at path: a single .eml file, an mbox file, a
+// Maildir (a directory with cur and new) or a directory of .eml files
+func ReadPath(path string) ([]Message, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if bytes.HasPrefix(data, []byte("From ")) {
+			return splitMbox(path, data), nil
+		}
+		return []Message{{Location: path, Raw: data}}, nil
+	}
+
+	if isMaildir(path) {
+		var files []string
+		for _, sub := range []string{"cur", "new"} {
+			entries, err := os.ReadDir(filepath.Join(path, sub))
+			if err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+					files = append(files, filepath.Join(path, sub, entry.Name()))
+				}
+			}
+		}
+		return readFiles(files)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".eml") {
+			files = append(files, filepath.Join(path, entry.Name()))
+		}
+	}
+	return readFiles(files)
+}
+
+func isMaildir(path string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		if info, err := os.Stat(filepath.Join(path, sub)); err == nil && info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+func readFiles(files []string) ([]Message, error) {
+	sort.Strings(files)
+	messages := make([]Message, 0, len(files))
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", file, err)
+		}
+		messages = append(messages, Message{Location: file, Raw: data})
+	}
+	return messages, nil
+}
+
+// splitMbox splits an mbox file on its "From " separator lines, undoing the
+// ">From " quoting of mboxrd
+func splitMbox(path string, data []byte) []Message {
+	var messages []Message
+	var current bytes.Buffer
+	inMessage := false
+	flush := func() {
+		if inMessage {
+			raw := bytes.TrimRight(current.Bytes(), "\r\n")
+			messages = append(messages, Message{
+				Location: fmt.Sprintf("%s#%d", path, len(messages)+1),
+				Raw:      append([]byte(nil), raw...),
+			})
+		}
+		current.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	previousBlank := true
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if previousBlank && bytes.HasPrefix(line, []byte("From ")) {
+			flush()
+			inMessage = true
+			previousBlank = false
+			continue
+		}
+
+		if quoted := bytes.TrimLeft(line, ">"); len(quoted) < len(line) && bytes.HasPrefix(quoted, []byte("From ")) {
+			line = line[1:]
+		}
+		current.Write(line)
+		current.WriteByte('\n')
+		previousBlank = len(bytes.TrimRight(line, "\r")) == 0
+	}
+	flush()
+
+	return messages
+}
+
+// ParsePath returns the transactions described by the alert emails at path
+// and how many messages were not recognised as alerts. Messages that look
+// like alerts but can't be read are returned as errors alongside, so one
+// odd email doesn't stop the rest.
+func ParsePath(path string) ([]*domain.Transaction, int, []error, error) {
+	messages, err := ReadPath(path)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var transactions []*domain.Transaction
+	var errs []error
+	skipped := 0
+	for _, msg := range messages {
+		tx, err := ParseMessage(msg)
+		switch {
+		case err != nil:
+			errs = append(errs, err)
+		case tx == nil:
+			skipped++
+		default:
+			transactions = append(transactions, tx)
+		}
+	}
+	return transactions, skipped, errs, nil
+}
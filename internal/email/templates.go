@@ -0,0 +1,144 @@
+package email
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// alertSenders are the domains alerts are accepted from. Interac sends the
+// e-Transfer notifications on RBC's behalf.
+var alertSenders = []string{"rbc.com", "payments.interac.ca"}
+
+var (
+	alertDateLayouts     = []string{"January 2, 2006", "Jan 2, 2006", "2006-01-02"}
+	alertDateTimeLayouts = []string{"January 2, 2006 3:04PM", "Jan 2, 2006 3:04PM", "2006-01-02 15:04"}
+)
+
+// alertTemplate recognises one kind of alert. Patterns run on the body with
+// whitespace collapsed and use named groups: amount (required), currency,
+// merchant, last4 (the card or account), date and time.
+type alertTemplate struct {
+	name        string
+	accountType string
+	direction   domain.Direction
+	pattern     *regexp.Regexp
+}
+
+const (
+	alertAmount  = `\$?(?P<amount>[\d,]+\.\d{2})(?: ?\(?(?P<currency>CAD|USD)\)?)?`
+	alertEnding  = `ending (?:in )?(?:\*+)?(?P<last4>\d{4})`
+	alertWhen    = `(?: on (?P<date>[A-Z][a-z]+\.? \d{1,2}, \d{4})(?: at (?P<time>\d{1,2}:\d{2} ?[AaPp]\.?[Mm]\.?))?)?`
+	alertAccount = `(?:your )?(?:RBC )?(?:[\w ]*?)account ` + alertEnding
+	alertCard    = `(?:your )?(?:RBC )?(?:[\w ]*?)card ` + alertEnding
+)
+
+var alertTemplates = []alertTemplate{
+	{
+		// "A purchase of $23.45 was made at TIM HORTONS #1234 on your RBC Visa
+		// card ending in 1234 on January 15, 2025 at 10:23 AM."
+		name:        "card purchase",
+		accountType: "visa",
+		direction:   domain.Out,
+		pattern:     regexp.MustCompile(`(?i)purchase of ` + alertAmount + ` (?:was made |was authorized )?at (?P<merchant>.+?) (?:on|with|using) ` + alertCard + alertWhen),
+	},
+	{
+		// "A refund of $10.00 from AMAZON.CA was credited to your RBC Visa card
+		// ending in 1234."
+		name:        "card refund",
+		accountType: "visa",
+		direction:   domain.In,
+		pattern:     regexp.MustCompile(`(?i)(?:refund|credit) of ` + alertAmount + `(?: from (?P<merchant>.+?))? was (?:credited|applied) to ` + alertCard + alertWhen),
+	},
+	{
+		// "You sent an Interac e-Transfer of $50.00 (CAD) to JOHN DOE from your
+		// account ending in 3878."
+		name:        "e-Transfer sent",
+		accountType: "chequing",
+		direction:   domain.Out,
+		pattern:     regexp.MustCompile(`(?i)you sent (?:an Interac e-Transfer of )?` + alertAmount + ` to (?P<merchant>.+?) from ` + alertAccount + alertWhen),
+	},
+	{
+		// "JOHN DOE sent you $50.00 (CAD) and the money has been automatically
+		// deposited into your account ending in 3878." The name is matched case
+		// sensitively, so "Hi there" before it is not taken for the sender.
+		name:        "e-Transfer received",
+		accountType: "chequing",
+		direction:   domain.In,
+		pattern:     regexp.MustCompile(`(?i)(?P<merchant>(?-i:[A-Z][\w.'&-]*(?: [A-Z][\w.'&-]*)*)) sent you ` + alertAmount + `(?:,)? and the money has been (?:automatically )?deposited (?:in|into) ` + alertAccount + alertWhen),
+	},
+	{
+		// "A withdrawal of $100.00 was made from your account ending in 3878."
+		name:        "withdrawal",
+		accountType: "chequing",
+		direction:   domain.Out,
+		pattern:     regexp.MustCompile(`(?i)(?:withdrawal|debit|payment) of ` + alertAmount + ` (?:to (?P<merchant>.+?) )?(?:was made |has been made |was taken )?from ` + alertAccount + alertWhen),
+	},
+	{
+		// "A deposit of $1,234.56 was made to your account ending in 3878."
+		name:        "deposit",
+		accountType: "chequing",
+		direction:   domain.In,
+		pattern:     regexp.MustCompile(`(?i)deposit of ` + alertAmount + ` (?:from (?P<merchant>.+?) )?(?:was made |has been made )?(?:to|into) ` + alertAccount + alertWhen),
+	},
+}
+
+// matchAlert runs the templates over an alert body, returning nil when none
+// of them match
+func matchAlert(body string, sent time.Time) (*domain.Transaction, error) {
+	for _, t := range alertTemplates {
+		m := t.pattern.FindStringSubmatch(body)
+		if m == nil {
+			continue
+		}
+		group := func(name string) string {
+			if i := t.pattern.SubexpIndex(name); i >= 0 {
+				return strings.TrimSpace(m[i])
+			}
+			return ""
+		}
+
+		amount, err := strconv.ParseFloat(strings.ReplaceAll(group("amount"), ",", ""), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s alert: invalid amount %q", t.name, group("amount"))
+		}
+		txDate, err := alertTime(group("date"), group("time"), sent)
+		if err != nil {
+			return nil, fmt.Errorf("%s alert: %w", t.name, err)
+		}
+
+		currency := strings.ToUpper(group("currency"))
+		if currency == "" {
+			currency = domain.DefaultCurrency
+		}
+		accountType := t.accountType
+		if accountType == "visa" && strings.Contains(strings.ToLower(m[0]), "mastercard") {
+			accountType = "credit_card"
+		}
+
+		last4 := group("last4")
+		merchant := strings.TrimSuffix(group("merchant"), ".")
+		description := t.name
+		if merchant != "" {
+			description = merchant
+		}
+
+		return &domain.Transaction{
+			TxDate:                 txDate,
+			TxAmount:               amount,
+			TxCurrency:             currency,
+			TxDirection:            t.direction,
+			TxDesc:                 description,
+			Merchant:               merchant,
+			Source:                 domain.SourceEmail,
+			StatementAccountNumber: &last4,
+			StatementAccountType:   accountType,
+			StatementBank:          "RBC",
+		}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,159 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestMatchAlert(t *testing.T) {
+	sent := time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		body        string
+		amount      float64
+		currency    string
+		direction   domain.Direction
+		merchant    string
+		last4       string
+		accountType string
+		date        time.Time
+	}{
+		{
+			name:        "card purchase",
+			body:        "A purchase of $23.45 was made at TIM HORTONS #1234 on your RBC Visa card ending in 1234 on January 15, 2025 at 10:23 AM.",
+			amount:      23.45,
+			currency:    "CAD",
+			direction:   domain.Out,
+			merchant:    "TIM HORTONS #1234",
+			last4:       "1234",
+			accountType: "visa",
+			date:        time.Date(2025, 1, 15, 10, 23, 0, 0, time.UTC),
+		},
+		{
+			name:        "card purchase in USD on a Mastercard",
+			body:        "A purchase of $1,050.00 USD was authorized at AMAZON.COM with your RBC Mastercard card ending in *9876 on Jan. 14, 2025 at 9:05 p.m.",
+			amount:      1050,
+			currency:    "USD",
+			direction:   domain.Out,
+			merchant:    "AMAZON.COM",
+			last4:       "9876",
+			accountType: "credit_card",
+			date:        time.Date(2025, 1, 14, 21, 5, 0, 0, time.UTC),
+		},
+		{
+			name:        "card refund",
+			body:        "A refund of $10.00 from AMAZON.CA was credited to your RBC Visa card ending in 1234.",
+			amount:      10,
+			currency:    "CAD",
+			direction:   domain.In,
+			merchant:    "AMAZON.CA",
+			last4:       "1234",
+			accountType: "visa",
+			date:        time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "e-Transfer sent",
+			body:        "You sent an Interac e-Transfer of $50.00 (CAD) to JOHN DOE from your account ending in 3878 on January 15, 2025.",
+			amount:      50,
+			currency:    "CAD",
+			direction:   domain.Out,
+			merchant:    "JOHN DOE",
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "e-Transfer received",
+			body:        "JOHN DOE sent you $50.00 (CAD) and the money has been automatically deposited into your account ending in 3878.",
+			amount:      50,
+			currency:    "CAD",
+			direction:   domain.In,
+			merchant:    "JOHN DOE",
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "e-Transfer received after a greeting",
+			body:        "Hi there JOHN DOE sent you $75.25, and the money has been deposited into your RBC account ending in 3878.",
+			amount:      75.25,
+			currency:    "CAD",
+			direction:   domain.In,
+			merchant:    "JOHN DOE",
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "withdrawal",
+			body:        "A withdrawal of $100.00 was made from your account ending in 3878 on January 15, 2025 at 4:10 PM.",
+			amount:      100,
+			currency:    "CAD",
+			direction:   domain.Out,
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 15, 16, 10, 0, 0, time.UTC),
+		},
+		{
+			name:        "bill payment",
+			body:        "A payment of $82.10 to HYDRO ONE has been made from your RBC chequing account ending in 3878.",
+			amount:      82.10,
+			currency:    "CAD",
+			direction:   domain.Out,
+			merchant:    "HYDRO ONE",
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 16, 8, 30, 0, 0, time.UTC),
+		},
+		{
+			name:        "deposit",
+			body:        "A deposit of $1,234.56 from ACME PAYROLL was made to your account ending in 3878 on Jan 15, 2025.",
+			amount:      1234.56,
+			currency:    "CAD",
+			direction:   domain.In,
+			merchant:    "ACME PAYROLL",
+			last4:       "3878",
+			accountType: "chequing",
+			date:        time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := matchAlert(tt.body, sent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx == nil {
+				t.Fatal("no template matched")
+			}
+			if tx.TxAmount != tt.amount || tx.TxCurrency != tt.currency || tx.TxDirection != tt.direction {
+				t.Errorf("got %.2f %s %s, want %.2f %s %s", tx.TxAmount, tx.TxCurrency, tx.TxDirection, tt.amount, tt.currency, tt.direction)
+			}
+			if tx.Merchant != tt.merchant {
+				t.Errorf("got merchant %q, want %q", tx.Merchant, tt.merchant)
+			}
+			if *tx.StatementAccountNumber != tt.last4 || tx.StatementAccountType != tt.accountType {
+				t.Errorf("got account %s %s, want %s %s", *tx.StatementAccountNumber, tx.StatementAccountType, tt.last4, tt.accountType)
+			}
+			if !tx.TxDate.Equal(tt.date) {
+				t.Errorf("got date %s, want %s", tx.TxDate, tt.date)
+			}
+		})
+	}
+}
+
+func TestMatchAlertIgnoresOtherMessages(t *testing.T) {
+	for _, body := range []string{
+		"Your monthly statement is ready to view in Online Banking.",
+		"Your RBC Visa card ending in 1234 has a new payment due date.",
+	} {
+		tx, err := matchAlert(body, time.Now())
+		if err != nil || tx != nil {
+			t.Errorf("%q: got %+v, %v, want no match", body, tx, err)
+		}
+	}
+}
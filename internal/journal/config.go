@@ -0,0 +1,126 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// Config maps statement accounts and categories onto journal account names.
+// Every field is optional, unmapped accounts get a generated name.
+type Config struct {
+	// Accounts is keyed by statement account number, last 4 digits or account
+	// name
+	Accounts map[string]string `json:"accounts"`
+	// Types is keyed by statement account type (chequing, savings, visa, ...)
+	Types map[string]string `json:"types"`
+	// Categories is keyed by parser or ariand category
+	Categories     map[string]string `json:"categories"`
+	ExpenseAccount string            `json:"expense_account"`
+	IncomeAccount  string            `json:"income_account"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		ExpenseAccount: "Expenses:Uncategorized",
+		IncomeAccount:  "Income:Uncategorized",
+	}
+}
+
+func LoadConfig(path string) (*Config, error) {
+	cfg := DefaultConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal config: %w", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse journal config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// StatementAccount resolves the journal account for a statement account,
+// trying the full number, its last 4 digits, the account name and the account
+// type before falling back to a generated name
+func (c *Config) StatementAccount(number, accountType, name string) string {
+	candidates := []string{number}
+	if len(number) > 4 {
+		candidates = append(candidates, number[len(number)-4:])
+	}
+	candidates = append(candidates, name)
+
+	for _, key := range candidates {
+		if key == "" {
+			continue
+		}
+		if account, ok := c.Accounts[key]; ok {
+			return account
+		}
+	}
+	if account, ok := c.Types[accountType]; ok {
+		return account
+	}
+
+	root := "Assets"
+	if IsLiability(accountType) {
+		root = "Liabilities"
+	}
+	parts := []string{root, component(accountType)}
+	if number != "" {
+		suffix := number
+		if len(suffix) > 4 {
+			suffix = suffix[len(suffix)-4:]
+		}
+		parts = append(parts, component(suffix))
+	}
+	return strings.Join(parts, ":")
+}
+
+// CounterAccount resolves the expense or income side of a transaction
+func (c *Config) CounterAccount(category string, incoming bool) string {
+	if account, ok := c.Categories[category]; ok && category != "" {
+		return account
+	}
+	if incoming {
+		return c.IncomeAccount
+	}
+	return c.ExpenseAccount
+}
+
+// IsLiability reports whether balances of this account type are amounts owed
+func IsLiability(accountType string) bool {
+	switch strings.ToLower(accountType) {
+	case "visa", "mastercard", "amex", "credit", "credit_card":
+		return true
+	}
+	return false
+}
+
+// component turns free text into a valid account name component: letters,
+// digits and dashes, starting with a capital letter or digit
+func component(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteRune('-')
+		}
+	}
+
+	out := strings.Trim(b.String(), "-")
+	if out == "" {
+		return "Unknown"
+	}
+	runes := []rune(out)
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
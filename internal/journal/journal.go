@@ -0,0 +1,266 @@
+// Package journal writes transactions as plain-text accounting journals for
+// Beancount and hledger
+package journal
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+type Style string
+
+const (
+	Beancount Style = "beancount"
+	Hledger   Style = "hledger"
+)
+
+func ParseStyle(s string) (Style, error) {
+	switch style := Style(strings.ToLower(s)); style {
+	case Beancount, Hledger:
+		return style, nil
+	}
+	return "", fmt.Errorf("unknown journal format: %s", s)
+}
+
+const postingWidth = 48
+
+type entry struct {
+	date    time.Time
+	balance bool
+	lines   []string
+}
+
+// Write renders transactions, plus a balance assertion for every statement
+// with a closing balance. Entries are ordered by date, and on the same date
+// transactions come before assertions.
+func Write(w io.Writer, style Style, cfg *Config, transactions []*domain.Transaction, statements []*domain.Statement) error {
+	statementsByFile := make(map[string]*domain.Statement)
+	for _, s := range statements {
+		statementsByFile[s.SourceFilePath] = s
+	}
+
+	var entries []entry
+	opened := make(map[string]time.Time)
+	open := func(account string, date time.Time) {
+		if date.IsZero() {
+			return
+		}
+		if first, ok := opened[account]; !ok || date.Before(first) {
+			opened[account] = date
+		}
+	}
+
+	for _, tx := range transactions {
+		number := ""
+		if tx.StatementAccountNumber != nil {
+			number = *tx.StatementAccountNumber
+		}
+		account := cfg.StatementAccount(number, tx.StatementAccountType, tx.StatementAccountName)
+		counter := cfg.CounterAccount(tx.Category, tx.TxDirection == domain.In)
+		open(account, tx.TxDate)
+		open(counter, tx.TxDate)
+
+		amount := tx.TxAmount
+		if tx.TxDirection == domain.Out {
+			amount = -amount
+		}
+
+		meta := transactionMeta(tx, statementsByFile[tx.SourceFilePath])
+		entries = append(entries, entry{
+			date:  tx.TxDate,
+			lines: formatTransaction(style, tx, meta, account, counter, amount),
+		})
+	}
+
+	for _, s := range statements {
+		if s.ClosingBalance == nil || s.PeriodEnd.IsZero() {
+			continue
+		}
+		account := cfg.StatementAccount(s.AccountNumber, s.AccountType, s.AccountName)
+		balance := *s.ClosingBalance
+		if IsLiability(s.AccountType) {
+			balance = -balance
+		}
+		currency := statementCurrency(s, transactions)
+		// without a period start the account is opened by its earliest
+		// transaction, or on the assertion date when it has none
+		opensAt := s.PeriodStart
+		if opensAt.IsZero() {
+			opensAt = s.PeriodEnd
+		}
+		open(account, opensAt)
+
+		entries = append(entries, entry{
+			date:    s.PeriodEnd,
+			balance: true,
+			lines:   formatBalance(style, s, account, balance, currency),
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].date.Equal(entries[j].date) {
+			return entries[i].date.Before(entries[j].date)
+		}
+		return !entries[i].balance && entries[j].balance
+	})
+
+	bw := bufio.NewWriter(w)
+	writeOpenDirectives(bw, style, opened)
+	for _, e := range entries {
+		for _, line := range e.lines {
+			bw.WriteString(line)
+			bw.WriteByte('\n')
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+type metaItem struct {
+	key   string
+	value string
+}
+
+func transactionMeta(tx *domain.Transaction, statement *domain.Statement) []metaItem {
+	var meta []metaItem
+	if tx.SourceFilePath != "" {
+		meta = append(meta, metaItem{"source_file", filepath.Base(tx.SourceFilePath)})
+	}
+	if statement != nil && !statement.PeriodStart.IsZero() && !statement.PeriodEnd.IsZero() {
+		meta = append(meta, metaItem{"statement_period",
+			statement.PeriodStart.Format(time.DateOnly) + ".." + statement.PeriodEnd.Format(time.DateOnly)})
+	}
+	if !tx.PostingDate.IsZero() && !tx.PostingDate.Equal(tx.TxDate) {
+		meta = append(meta, metaItem{"posting_date", tx.PostingDate.Format(time.DateOnly)})
+	}
+	if tx.ExternalID != "" {
+		meta = append(meta, metaItem{"reference", tx.ExternalID})
+	}
+	return meta
+}
+
+func formatTransaction(style Style, tx *domain.Transaction, meta []metaItem, account, counter string, amount float64) []string {
+	date := tx.TxDate.Format(time.DateOnly)
+	var lines []string
+
+	switch style {
+	case Beancount:
+		header := date + " *"
+		if tx.Merchant != "" {
+			header += " " + quote(tx.Merchant)
+		}
+		header += " " + quote(tx.TxDesc)
+		lines = append(lines, header)
+		for _, m := range meta {
+			lines = append(lines, fmt.Sprintf("  %s: %s", m.key, quote(m.value)))
+		}
+		lines = append(lines,
+			posting("  ", account, formatAmount(amount, tx.TxCurrency)),
+			"  "+counter,
+		)
+	case Hledger:
+		desc := cleanDescription(tx.TxDesc)
+		if tx.Merchant != "" {
+			desc = cleanDescription(tx.Merchant) + " | " + desc
+		}
+		lines = append(lines, date+" * "+desc)
+		for _, m := range meta {
+			lines = append(lines, fmt.Sprintf("    ; %s: %s", m.key, cleanDescription(m.value)))
+		}
+		lines = append(lines,
+			posting("    ", account, formatAmount(amount, tx.TxCurrency)),
+			"    "+counter,
+		)
+	}
+
+	return lines
+}
+
+func formatBalance(style Style, s *domain.Statement, account string, balance float64, currency string) []string {
+	source := filepath.Base(s.SourceFilePath)
+
+	switch style {
+	case Beancount:
+		// beancount checks the balance at the start of the day
+		date := s.PeriodEnd.AddDate(0, 0, 1).Format(time.DateOnly)
+		return []string{
+			fmt.Sprintf("%s balance %s %s", date, account, formatAmount(balance, currency)),
+			fmt.Sprintf("  source_file: %s", quote(source)),
+		}
+	default:
+		return []string{
+			fmt.Sprintf("%s * closing balance", s.PeriodEnd.Format(time.DateOnly)),
+			fmt.Sprintf("    ; source_file: %s", cleanDescription(source)),
+			posting("    ", account, formatAmount(0, currency)+" = "+formatAmount(balance, currency)),
+		}
+	}
+}
+
+func writeOpenDirectives(w *bufio.Writer, style Style, opened map[string]time.Time) {
+	if len(opened) == 0 {
+		return
+	}
+
+	accounts := make([]string, 0, len(opened))
+	for account := range opened {
+		accounts = append(accounts, account)
+	}
+	sort.Strings(accounts)
+
+	for _, account := range accounts {
+		switch style {
+		case Beancount:
+			fmt.Fprintf(w, "%s open %s\n", opened[account].Format(time.DateOnly), account)
+		case Hledger:
+			fmt.Fprintf(w, "account %s\n", account)
+		}
+	}
+	w.WriteByte('\n')
+}
+
+// statementCurrency is the statement's own currency, or else the currency of
+// its transactions for sources that don't state it separately
+func statementCurrency(s *domain.Statement, transactions []*domain.Transaction) string {
+	if s.Currency != "" {
+		return s.Currency
+	}
+	for _, tx := range transactions {
+		if tx.SourceFilePath == s.SourceFilePath && tx.TxCurrency != "" {
+			return tx.TxCurrency
+		}
+	}
+	return domain.DefaultCurrency
+}
+
+func posting(indent, account, amount string) string {
+	pad := postingWidth - len(account)
+	if pad < 2 {
+		pad = 2
+	}
+	return indent + account + strings.Repeat(" ", pad) + amount
+}
+
+func formatAmount(amount float64, currency string) string {
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
+
+// cleanDescription strips characters hledger would read as a comment or line
+// break
+func cleanDescription(s string) string {
+	s = strings.ReplaceAll(s, ";", ",")
+	s = strings.ReplaceAll(s, "\n", " ")
+	return strings.TrimSpace(s)
+}
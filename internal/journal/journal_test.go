@@ -0,0 +1,52 @@
+package journal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestWriteOpensAccountsWithoutPeriodStart(t *testing.T) {
+	closing := 250.0
+	number := "5163878"
+	statement := &domain.Statement{
+		SourceFilePath: "statement.pdf",
+		AccountNumber:  number,
+		AccountType:    "chequing",
+		Currency:       "CAD",
+		ClosingBalance: &closing,
+		PeriodEnd:      time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC),
+	}
+	tx := &domain.Transaction{
+		TxDate:                 time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		TxAmount:               4.50,
+		TxCurrency:             "CAD",
+		TxDirection:            domain.Out,
+		TxDesc:                 "TIM HORTONS",
+		StatementAccountNumber: &number,
+		StatementAccountType:   "chequing",
+		SourceFilePath:         "statement.pdf",
+	}
+
+	tests := []struct {
+		name         string
+		transactions []*domain.Transaction
+		open         string
+	}{
+		{"earliest transaction", []*domain.Transaction{tx}, "2025-01-10 open Assets:Chequing:3878"},
+		{"assertion date", nil, "2025-01-31 open Assets:Chequing:3878"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out strings.Builder
+			if err := Write(&out, Beancount, DefaultConfig(), tt.transactions, []*domain.Statement{statement}); err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(out.String(), "0001-01-01") || !strings.Contains(out.String(), tt.open) {
+				t.Fatalf("want %q directives, got:\n%s", tt.open, out.String())
+			}
+		})
+	}
+}
@@ -1 +1,174 @@
 package mapping
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/alias"
+	pb "null-statement-parser/internal/gen/null/v1"
+)
+
+// File maps statement accounts to existing ariand accounts so imports can run
+// without prompting. Keys are any form of the statement account number, such
+// as its last 4 digits, values an ariand account ID or name. Create describes
+// the accounts to create, under the same keys, for statement accounts that
+// aren't in ariand yet.
+type File struct {
+	Accounts map[string]string     `json:"accounts"`
+	Create   map[string]NewAccount `json:"create,omitempty"`
+}
+
+// AccountTypes are the types a new account can have
+var AccountTypes = []string{"chequing", "savings", "credit_card", "investment", "other"}
+
+// NewAccount is an account to create for a statement account. Empty fields
+// take the statement's values.
+type NewAccount struct {
+	Name         string `json:"name,omitempty"`
+	FriendlyName string `json:"friendly_name,omitempty"`
+	Bank         string `json:"bank,omitempty"`
+	// Type is one of AccountTypes
+	Type     string `json:"type,omitempty"`
+	Currency string `json:"currency,omitempty"`
+	// Color is a hex color such as "#1f77b4"
+	Color string `json:"color,omitempty"`
+	// AnchorBalance is the known balance on AnchorDate (YYYY-MM-DD), the
+	// starting point of the running balance
+	AnchorBalance *float64 `json:"anchor_balance,omitempty"`
+	AnchorDate    string   `json:"anchor_date,omitempty"`
+}
+
+var hexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// WithDefaults fills the empty fields of a from defaults
+func (a NewAccount) WithDefaults(defaults NewAccount) NewAccount {
+	fill := func(field *string, value string) {
+		if *field == "" {
+			*field = value
+		}
+	}
+	fill(&a.Name, defaults.Name)
+	fill(&a.FriendlyName, defaults.FriendlyName)
+	fill(&a.Bank, defaults.Bank)
+	fill(&a.Type, defaults.Type)
+	fill(&a.Currency, defaults.Currency)
+	fill(&a.Color, defaults.Color)
+	if a.AnchorBalance == nil {
+		a.AnchorBalance, a.AnchorDate = defaults.AnchorBalance, defaults.AnchorDate
+	}
+	return a
+}
+
+// Validate checks the type, currency, color and anchor date
+func (a NewAccount) Validate() error {
+	if a.Name == "" {
+		return fmt.Errorf("account name is required")
+	}
+	if a.Type != "" && !slices.Contains(AccountTypes, a.Type) {
+		return fmt.Errorf("invalid account type %q (want %s)", a.Type, strings.Join(AccountTypes, ", "))
+	}
+	if !isCurrencyCode(a.Currency) {
+		return fmt.Errorf("invalid currency %q (want a 3 letter code such as CAD)", a.Currency)
+	}
+	if a.Color != "" && !hexColor.MatchString(a.Color) {
+		return fmt.Errorf("invalid color %q (want #rrggbb)", a.Color)
+	}
+	if _, err := a.ParsedAnchorDate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParsedAnchorDate returns AnchorDate, the zero time when it is not set
+func (a NewAccount) ParsedAnchorDate() (time.Time, error) {
+	if a.AnchorDate == "" {
+		return time.Time{}, nil
+	}
+	date, err := time.Parse(time.DateOnly, a.AnchorDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid anchor date %q (want YYYY-MM-DD)", a.AnchorDate)
+	}
+	return date, nil
+}
+
+func isCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+func LoadFile(path string) (*File, error) {
+	file := &File{}
+	if path == "" {
+		return file, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read account mapping: %w", err)
+	}
+	if err := json.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse account mapping: %w", err)
+	}
+	return file, nil
+}
+
+// NewAccountFor returns how to create the account for a statement account
+// number, if the file says
+func (f *File) NewAccountFor(statementAccount string) (NewAccount, bool) {
+	return find(f.Create, statementAccount)
+}
+
+// find returns the entry for a statement account. Keys may be any form of the
+// number: the most specific variant wins, whether it is the key itself or
+// one of the key's variants.
+func find[T any](entries map[string]T, statementAccount string) (T, bool) {
+	variants := alias.Variants(statementAccount)
+	for _, v := range variants {
+		if entry, ok := entries[v]; ok {
+			return entry, true
+		}
+	}
+
+	keys := slices.Sorted(maps.Keys(entries))
+	for _, v := range variants {
+		for _, key := range keys {
+			if slices.Contains(alias.Variants(key), v) {
+				return entries[key], true
+			}
+		}
+	}
+
+	var zero T
+	return zero, false
+}
+
+// Lookup returns the mapped account for a statement account number, nil when
+// the file has no entry for it
+func (f *File) Lookup(statementAccount string, accounts []*pb.Account) (*pb.Account, error) {
+	target, ok := find(f.Accounts, statementAccount)
+	if !ok {
+		return nil, nil
+	}
+
+	id, idErr := strconv.ParseInt(target, 10, 64)
+	for _, account := range accounts {
+		if (idErr == nil && account.Id == id) || strings.EqualFold(account.Name, target) {
+			return account, nil
+		}
+	}
+	return nil, fmt.Errorf("account mapping for '%s' points at unknown account '%s'", statementAccount, target)
+}
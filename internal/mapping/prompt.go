@@ -2,7 +2,9 @@ package mapping
 
 import (
 	"fmt"
+	"os"
 	"strconv"
+	"strings"
 
 	pb "null-statement-parser/internal/gen/null/v1"
 
@@ -41,7 +43,7 @@ func PromptForAccountMapping(statementAccountNumber string, existingAccounts []*
 				Options(options...).
 				Value(&selectedOption),
 		),
-	)
+	).WithOutput(os.Stderr)
 
 	err := form.Run()
 	if err != nil {
@@ -55,3 +57,100 @@ func PromptForAccountMapping(statementAccountNumber string, existingAccounts []*
 
 	return selectedOption, isNewAccount, nil
 }
+
+// PromptNewAccount asks for the details of an account to create for a
+// statement account, starting from defaults
+func PromptNewAccount(statementAccountNumber string, defaults NewAccount) (NewAccount, error) {
+	account := defaults
+	anchorBalance := ""
+	if defaults.AnchorBalance != nil {
+		anchorBalance = strconv.FormatFloat(*defaults.AnchorBalance, 'f', 2, 64)
+	}
+
+	typeOptions := make([]huh.Option[string], 0, len(AccountTypes))
+	for _, t := range AccountTypes {
+		typeOptions = append(typeOptions, huh.NewOption(t, t))
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title(fmt.Sprintf("New account for '%s'", statementAccountNumber)).
+				Description("Name").
+				Value(&account.Name).
+				Validate(func(s string) error {
+					if strings.TrimSpace(s) == "" {
+						return fmt.Errorf("name is required")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Friendly name").
+				Description("Shown instead of the name, optional").
+				Value(&account.FriendlyName),
+			huh.NewInput().
+				Title("Bank").
+				Value(&account.Bank),
+			huh.NewSelect[string]().
+				Title("Type").
+				Options(typeOptions...).
+				Value(&account.Type),
+			huh.NewInput().
+				Title("Currency").
+				Value(&account.Currency).
+				Validate(func(s string) error {
+					if !isCurrencyCode(strings.ToUpper(strings.TrimSpace(s))) {
+						return fmt.Errorf("want a 3 letter code such as CAD")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Color").
+				Description("#rrggbb, optional").
+				Value(&account.Color).
+				Validate(func(s string) error {
+					if s = strings.TrimSpace(s); s != "" && !hexColor.MatchString(s) {
+						return fmt.Errorf("want #rrggbb")
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Opening balance").
+				Description("Balance on the anchor date, optional").
+				Value(&anchorBalance).
+				Validate(func(s string) error {
+					if s = strings.TrimSpace(s); s != "" {
+						if _, err := strconv.ParseFloat(s, 64); err != nil {
+							return fmt.Errorf("want a number such as 1234.56")
+						}
+					}
+					return nil
+				}),
+			huh.NewInput().
+				Title("Anchor date").
+				Description("YYYY-MM-DD, optional").
+				Value(&account.AnchorDate).
+				Validate(func(s string) error {
+					_, err := NewAccount{AnchorDate: strings.TrimSpace(s)}.ParsedAnchorDate()
+					return err
+				}),
+		),
+	).WithOutput(os.Stderr)
+
+	if err := form.Run(); err != nil {
+		return NewAccount{}, fmt.Errorf("prompt failed: %w", err)
+	}
+
+	account.Name = strings.TrimSpace(account.Name)
+	account.FriendlyName = strings.TrimSpace(account.FriendlyName)
+	account.Bank = strings.TrimSpace(account.Bank)
+	account.Currency = strings.ToUpper(strings.TrimSpace(account.Currency))
+	account.Color = strings.TrimSpace(account.Color)
+	account.AnchorDate = strings.TrimSpace(account.AnchorDate)
+	account.AnchorBalance = nil
+	if s := strings.TrimSpace(anchorBalance); s != "" {
+		balance, _ := strconv.ParseFloat(s, 64)
+		account.AnchorBalance = &balance
+	}
+	return account, nil
+}
@@ -0,0 +1,41 @@
+// Package normalized reads and writes parsed transactions in an intermediate
+// file, so parsing and uploading can happen on different machines
+package normalized
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+type Format string
+
+const (
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+)
+
+// Version is bumped whenever the JSON document layout changes incompatibly
+const Version = 1
+
+// ResolveFormat returns the explicitly requested format, or guesses it from
+// the file extension. JSON is the default for stdout and unknown extensions.
+func ResolveFormat(format, path string) (Format, error) {
+	if format != "" {
+		switch f := Format(strings.ToLower(format)); f {
+		case JSON, NDJSON, CSV:
+			return f, nil
+		}
+		return "", fmt.Errorf("unknown format: %s", format)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ndjson", ".jsonl":
+		return NDJSON, nil
+	case ".csv":
+		return CSV, nil
+	default:
+		return JSON, nil
+	}
+}
@@ -0,0 +1,161 @@
+package normalized
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// requiredCSVColumns must be present when reading CSV, the remaining columns
+// of csvHeader are optional
+var requiredCSVColumns = []string{
+	"tx_date",
+	"tx_amount",
+	"tx_currency",
+	"tx_direction",
+	"tx_desc",
+	"statement_account_number",
+	"statement_account_type",
+}
+
+func Read(r io.Reader, format Format) (*Document, error) {
+	var transactions []*domain.Transaction
+	var err error
+
+	switch format {
+	case JSON:
+		var doc Document
+		if err := json.NewDecoder(r).Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode JSON: %w", err)
+		}
+		if doc.Version > Version {
+			return nil, fmt.Errorf("unsupported document version %d (max %d)", doc.Version, Version)
+		}
+		return &doc, nil
+	case NDJSON:
+		transactions, err = readNDJSON(r)
+	case CSV:
+		transactions, err = readCSV(r)
+	default:
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{Version: Version, Transactions: transactions}, nil
+}
+
+func ReadFile(path string, format Format) (*Document, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return Read(file, format)
+}
+
+func readNDJSON(r io.Reader) ([]*domain.Transaction, error) {
+	var transactions []*domain.Transaction
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var tx domain.Transaction
+		if err := json.Unmarshal(scanner.Bytes(), &tx); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		transactions = append(transactions, &tx)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read NDJSON: %w", err)
+	}
+	return transactions, nil
+}
+
+func readCSV(r io.Reader) ([]*domain.Transaction, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	colIndices := make(map[string]int)
+	for i, col := range records[0] {
+		colIndices[col] = i
+	}
+	for _, col := range requiredCSVColumns {
+		if _, ok := colIndices[col]; !ok {
+			return nil, fmt.Errorf("missing required column: %s", col)
+		}
+	}
+
+	var transactions []*domain.Transaction
+	for i, record := range records[1:] {
+		getCol := func(name string) string {
+			if idx, ok := colIndices[name]; ok && idx < len(record) {
+				return record[idx]
+			}
+			return ""
+		}
+
+		txDate, err := time.Parse(time.RFC3339, getCol("tx_date"))
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid date: %w", i+2, err)
+		}
+		var postingDate time.Time
+		if value := getCol("posting_date"); value != "" {
+			if postingDate, err = time.Parse(time.RFC3339, value); err != nil {
+				return nil, fmt.Errorf("row %d: invalid posting date: %w", i+2, err)
+			}
+		}
+		amount, err := strconv.ParseFloat(getCol("tx_amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid amount: %w", i+2, err)
+		}
+		var direction domain.Direction
+		if err := direction.UnmarshalText([]byte(getCol("tx_direction"))); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i+2, err)
+		}
+
+		tx := &domain.Transaction{
+			EmailID:              getCol("email_id"),
+			TxDate:               txDate,
+			PostingDate:          postingDate,
+			TxAmount:             amount,
+			TxCurrency:           getCol("tx_currency"),
+			TxDirection:          direction,
+			TxDesc:               getCol("tx_desc"),
+			Merchant:             getCol("merchant"),
+			UserNotes:            getCol("user_notes"),
+			Category:             getCol("category"),
+			ExternalID:           getCol("external_id"),
+			Source:               getCol("source"),
+			StatementAccountType: getCol("statement_account_type"),
+			StatementAccountName: getCol("statement_account_name"),
+			StatementBank:        getCol("statement_bank"),
+			SourceFilePath:       getCol("source_file_path"),
+		}
+		if accountNumber := getCol("statement_account_number"); accountNumber != "" {
+			tx.StatementAccountNumber = &accountNumber
+		}
+
+		transactions = append(transactions, tx)
+	}
+
+	return transactions, nil
+}
@@ -0,0 +1,123 @@
+package normalized
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// Document is the JSON layout. NDJSON and CSV only carry the transactions.
+type Document struct {
+	Version      int                   `json:"version"`
+	Transactions []*domain.Transaction `json:"transactions"`
+	Statements   []*domain.Statement   `json:"statements,omitempty"`
+}
+
+var csvHeader = []string{
+	"tx_date",
+	"posting_date",
+	"tx_amount",
+	"tx_currency",
+	"tx_direction",
+	"tx_desc",
+	"merchant",
+	"user_notes",
+	"category",
+	"external_id",
+	"email_id",
+	"source",
+	"statement_account_number",
+	"statement_account_type",
+	"statement_account_name",
+	"statement_bank",
+	"source_file_path",
+}
+
+func Write(w io.Writer, format Format, doc *Document) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(Document{Version: Version, Transactions: doc.Transactions, Statements: doc.Statements})
+	case NDJSON:
+		enc := json.NewEncoder(w)
+		for _, tx := range doc.Transactions {
+			if err := enc.Encode(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CSV:
+		return writeCSV(w, doc.Transactions)
+	default:
+		return fmt.Errorf("unknown format: %s", format)
+	}
+}
+
+// WriteFile writes to path, or to stdout when path is empty or "-"
+func WriteFile(path string, format Format, doc *Document) error {
+	if path == "" || path == "-" {
+		return Write(os.Stdout, format, doc)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if err := Write(file, format, doc); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+func writeCSV(w io.Writer, transactions []*domain.Transaction) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, tx := range transactions {
+		accountNumber := ""
+		if tx.StatementAccountNumber != nil {
+			accountNumber = *tx.StatementAccountNumber
+		}
+
+		postingDate := ""
+		if !tx.PostingDate.IsZero() {
+			postingDate = tx.PostingDate.Format(time.RFC3339)
+		}
+
+		record := []string{
+			tx.TxDate.Format(time.RFC3339),
+			postingDate,
+			strconv.FormatFloat(tx.TxAmount, 'f', 2, 64),
+			tx.TxCurrency,
+			tx.TxDirection.String(),
+			tx.TxDesc,
+			tx.Merchant,
+			tx.UserNotes,
+			tx.Category,
+			tx.ExternalID,
+			tx.EmailID,
+			tx.Source,
+			accountNumber,
+			tx.StatementAccountType,
+			tx.StatementAccountName,
+			tx.StatementBank,
+			tx.SourceFilePath,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,302 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// ISO 20022 bank-to-customer statement (camt.053) and account report
+// (camt.052). Element names are matched without their namespace so every
+// message version decodes the same way.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+	Reports    []camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+}
+
+type camtStatement struct {
+	ID      string `xml:"Id"`
+	Account struct {
+		IBAN     string `xml:"Id>IBAN"`
+		Other    string `xml:"Id>Othr>Id"`
+		Currency string `xml:"Ccy"`
+		Name     string `xml:"Nm"`
+	} `xml:"Acct"`
+	Period struct {
+		From string `xml:"FrDtTm"`
+		To   string `xml:"ToDtTm"`
+	} `xml:"FrToDt"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+type camtDate struct {
+	Date     string `xml:"Dt"`
+	DateTime string `xml:"DtTm"`
+}
+
+type camtBalance struct {
+	Code   string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount camtAmount `xml:"Amt"`
+	Sign   string     `xml:"CdtDbtInd"`
+	Date   camtDate   `xml:"Dt"`
+}
+
+// camtStatus is plain text before camt.053.001.08 and wrapped in Cd after
+type camtStatus struct {
+	Text string `xml:",chardata"`
+	Code string `xml:"Cd"`
+}
+
+type camtParty struct {
+	Name      string `xml:"Nm"`
+	PartyName string `xml:"Pty>Nm"`
+}
+
+type camtTxDetails struct {
+	Reference  string     `xml:"Refs>AcctSvcrRef"`
+	EndToEndID string     `xml:"Refs>EndToEndId"`
+	Amount     camtAmount `xml:"Amt"`
+	Debtor     camtParty  `xml:"RltdPties>Dbtr"`
+	Creditor   camtParty  `xml:"RltdPties>Cdtr"`
+	Remittance []string   `xml:"RmtInf>Ustrd"`
+	Info       string     `xml:"AddtlTxInf"`
+}
+
+type camtEntry struct {
+	Reference   string          `xml:"AcctSvcrRef"`
+	EntryRef    string          `xml:"NtryRef"`
+	Amount      camtAmount      `xml:"Amt"`
+	Sign        string          `xml:"CdtDbtInd"`
+	Status      camtStatus      `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	Info        string          `xml:"AddtlNtryInf"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+}
+
+// ParseCAMT parses a camt.053 statement or camt.052 report. Only booked
+// entries are kept. Batch entries whose details carry their own amounts are
+// split into one transaction per detail.
+func ParseCAMT(path string) ([]*domain.Transaction, []*domain.Statement, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open CAMT file: %w", err)
+	}
+
+	var doc camtDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse CAMT file: %w", err)
+	}
+
+	var transactions []*domain.Transaction
+	var statements []*domain.Statement
+	for _, stmt := range append(doc.Statements, doc.Reports...) {
+		stmtTxs, statement, err := stmt.convert(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: statement %s: %w", path, stmt.ID, err)
+		}
+		transactions = append(transactions, stmtTxs...)
+		statements = append(statements, statement)
+	}
+
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("%s: no Stmt or Rpt elements", path)
+	}
+	return transactions, statements, nil
+}
+
+func (s *camtStatement) convert(sourcePath string) ([]*domain.Transaction, *domain.Statement, error) {
+	accountNumber := s.Account.IBAN
+	if accountNumber == "" {
+		accountNumber = s.Account.Other
+	}
+	if accountNumber == "" {
+		return nil, nil, fmt.Errorf("no account identifier")
+	}
+
+	statement := &domain.Statement{
+		SourceFilePath: sourcePath,
+		AccountNumber:  accountNumber,
+		AccountType:    "chequing",
+		AccountName:    s.Account.Name,
+		Currency:       s.Account.Currency,
+	}
+	statement.PeriodStart, _ = parseCAMTDate(camtDate{DateTime: s.Period.From})
+	statement.PeriodEnd, _ = parseCAMTDate(camtDate{DateTime: s.Period.To})
+
+	for _, bal := range s.Balances {
+		amount, err := camtSigned(bal.Amount, bal.Sign)
+		if err != nil {
+			return nil, nil, err
+		}
+		date, _ := parseCAMTDate(bal.Date)
+
+		switch bal.Code {
+		case "OPBD", "PRCD":
+			statement.OpeningBalance = &amount
+			if statement.PeriodStart.IsZero() {
+				statement.PeriodStart = date
+			}
+		case "CLBD":
+			statement.ClosingBalance = &amount
+			if statement.PeriodEnd.IsZero() {
+				statement.PeriodEnd = date
+			}
+		}
+	}
+
+	var transactions []*domain.Transaction
+	for i, entry := range s.Entries {
+		status := entry.Status.Code
+		if status == "" {
+			status = strings.TrimSpace(entry.Status.Text)
+		}
+		if status != "" && status != "BOOK" {
+			continue
+		}
+
+		entryTxs, err := entry.convert(statement, s.Account.Currency)
+		if err != nil {
+			return nil, nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		transactions = append(transactions, entryTxs...)
+	}
+
+	return transactions, statement, nil
+}
+
+func (e *camtEntry) convert(statement *domain.Statement, accountCurrency string) ([]*domain.Transaction, error) {
+	bookingDate, err := parseCAMTDate(e.BookingDate)
+	if err != nil {
+		return nil, fmt.Errorf("booking date: %w", err)
+	}
+	// the value date is when the money moved, the booking date when the bank
+	// posted it
+	txDate, err := parseCAMTDate(e.ValueDate)
+	if err != nil {
+		txDate = bookingDate
+	}
+
+	reference := e.Reference
+	if reference == "" {
+		reference = e.EntryRef
+	}
+
+	split := len(e.Details) > 1
+	for _, d := range e.Details {
+		if d.Amount.Value == "" {
+			split = false
+		}
+	}
+
+	details := e.Details
+	if !split {
+		// one transaction for the entry, described by its first detail
+		var first camtTxDetails
+		if len(details) > 0 {
+			first = details[0]
+		}
+		first.Amount = e.Amount
+		first.Reference = reference
+		details = []camtTxDetails{first}
+	}
+
+	var transactions []*domain.Transaction
+	for i, d := range details {
+		amount, err := camtSigned(d.Amount, e.Sign)
+		if err != nil {
+			return nil, err
+		}
+		if amount == 0 {
+			continue
+		}
+
+		direction := domain.In
+		counterparty := d.Debtor
+		if amount < 0 {
+			direction = domain.Out
+			counterparty = d.Creditor
+			amount = -amount
+		}
+		merchant := counterparty.Name
+		if merchant == "" {
+			merchant = counterparty.PartyName
+		}
+
+		description := strings.TrimSpace(strings.Join(d.Remittance, " "))
+		for _, fallback := range []string{d.Info, e.Info, merchant} {
+			if description == "" {
+				description = strings.TrimSpace(fallback)
+			}
+		}
+
+		externalID := d.Reference
+		if split && externalID == "" && reference != "" {
+			externalID = fmt.Sprintf("%s/%d", reference, i+1)
+		}
+
+		currency := d.Amount.Currency
+		if currency == "" {
+			currency = accountCurrency
+		}
+
+		accountNumber := statement.AccountNumber
+		transactions = append(transactions, &domain.Transaction{
+			TxDate:                 txDate,
+			PostingDate:            bookingDate,
+			TxAmount:               amount,
+			TxCurrency:             currency,
+			TxDirection:            direction,
+			TxDesc:                 description,
+			Merchant:               merchant,
+			ExternalID:             externalID,
+			Source:                 domain.SourceCAMT,
+			StatementAccountNumber: &accountNumber,
+			StatementAccountType:   statement.AccountType,
+			StatementAccountName:   statement.AccountName,
+			SourceFilePath:         statement.SourceFilePath,
+		})
+	}
+
+	return transactions, nil
+}
+
+// camtSigned returns the amount, negative for DBIT
+func camtSigned(amount camtAmount, sign string) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount: %s", amount.Value)
+	}
+	if sign == "DBIT" {
+		value = -value
+	}
+	return value, nil
+}
+
+func parseCAMTDate(d camtDate) (time.Time, error) {
+	if d.Date != "" {
+		return time.Parse(time.DateOnly, strings.TrimSpace(d.Date))
+	}
+	if d.DateTime != "" {
+		s := strings.TrimSpace(d.DateTime)
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				// statements are about calendar days, drop the time of day
+				return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	return time.Time{}, fmt.Errorf("missing date")
+}
@@ -0,0 +1,139 @@
+package parser
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestParseCAMTStatement(t *testing.T) {
+	transactions, statements, err := ParseCAMT(filepath.Join("testdata", "camt053.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	statement := statements[0]
+	if statement.AccountNumber != "DE89370400440532013000" || statement.AccountName != "Girokonto" || statement.Currency != "EUR" {
+		t.Errorf("got account %s %q %s", statement.AccountNumber, statement.AccountName, statement.Currency)
+	}
+	if *statement.OpeningBalance != 1000 || *statement.ClosingBalance != 2370.50 {
+		t.Errorf("got balances %.2f, %.2f, want 1000.00, 2370.50", *statement.OpeningBalance, *statement.ClosingBalance)
+	}
+	// FrToDt wins over the balance dates
+	if statement.PeriodStart.Format(time.DateOnly) != "2025-01-01" || statement.PeriodEnd.Format(time.DateOnly) != "2025-01-31" {
+		t.Errorf("got period %s to %s", statement.PeriodStart, statement.PeriodEnd)
+	}
+
+	// the pending entry is dropped
+	for _, tx := range transactions {
+		if tx.ExternalID == "REF-004" {
+			t.Errorf("pending entry %s was imported", tx.ExternalID)
+		}
+	}
+
+	card, salary := transactions[0], transactions[1]
+	if card.TxDate.Format(time.DateOnly) != "2025-01-15" || card.PostingDate.Format(time.DateOnly) != "2025-01-16" {
+		t.Errorf("got dates %s / %s, want the value date 2025-01-15 and the booking date 2025-01-16", card.TxDate, card.PostingDate)
+	}
+	if card.TxDirection != domain.Out || card.Merchant != "BAECKEREI MUELLER" || card.TxDesc != "Kartenzahlung Filiale 12" {
+		t.Errorf("got %s %q %q, want the creditor as merchant of a debit", card.TxDirection, card.Merchant, card.TxDesc)
+	}
+	// no value date, and the booking date has a time of day
+	if !salary.TxDate.Equal(salary.PostingDate) || salary.PostingDate.Format(time.DateOnly) != "2025-01-20" {
+		t.Errorf("got dates %s / %s, want the booking date for both", salary.TxDate, salary.PostingDate)
+	}
+	if salary.TxDirection != domain.In || salary.Merchant != "ACME GMBH" || salary.TxDesc != "ACME GMBH" {
+		t.Errorf("got %s %q %q, want the debtor as merchant and description of a credit", salary.TxDirection, salary.Merchant, salary.TxDesc)
+	}
+}
+
+func TestParseCAMTBatchEntries(t *testing.T) {
+	transactions, _, err := ParseCAMT(filepath.Join("testdata", "camt053.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var batch []*domain.Transaction
+	for _, tx := range transactions {
+		if strings.HasPrefix(tx.ExternalID, "REF-003") || tx.ExternalID == "REF-005" {
+			batch = append(batch, tx)
+		}
+	}
+
+	tests := []struct {
+		reference string
+		amount    float64
+		merchant  string
+		desc      string
+	}{
+		// details with their own amounts are split, and numbered
+		{"REF-003/1", 60, "STADTWERKE", "Strom Januar"},
+		// no remittance text falls back to the entry's
+		{"REF-003/2", 40, "TELEKOM", "SAMMELUEBERWEISUNG"},
+		// details without amounts stay one transaction, described by the first
+		{"REF-005", 25, "VERLAG A", "Abo Zeitung"},
+	}
+	if len(batch) != len(tests) {
+		t.Fatalf("got %d batch transactions, want %d", len(batch), len(tests))
+	}
+	for i, tt := range tests {
+		tx := batch[i]
+		if tx.ExternalID != tt.reference || tx.TxAmount != tt.amount || tx.Merchant != tt.merchant || tx.TxDesc != tt.desc {
+			t.Errorf("got %s %.2f %q %q, want %s %.2f %q %q", tx.ExternalID, tx.TxAmount, tx.Merchant, tx.TxDesc, tt.reference, tt.amount, tt.merchant, tt.desc)
+		}
+	}
+}
+
+func TestParseCAMTReport(t *testing.T) {
+	transactions, statements, err := ParseCAMT(filepath.Join("testdata", "camt052.xml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := statements[0]
+	// a DBIT balance is overdrawn
+	if *statement.OpeningBalance != -150 || *statement.ClosingBalance != 50 {
+		t.Errorf("got balances %.2f, %.2f, want -150.00, 50.00", *statement.OpeningBalance, *statement.ClosingBalance)
+	}
+	// without FrToDt the period comes from the balance dates
+	if statement.PeriodStart.Format(time.DateOnly) != "2025-02-02" || statement.PeriodEnd.Format(time.DateOnly) != "2025-02-03" {
+		t.Errorf("got period %s to %s", statement.PeriodStart, statement.PeriodEnd)
+	}
+
+	// the status is wrapped in Cd from camt.052.001.08 on
+	checkTransactions(t, transactions, []wantTx{
+		{"2025-02-03", 200, domain.In, "GUTSCHRIFT", "0012345678", "chequing"},
+	})
+	if tx := transactions[0]; tx.ExternalID != "NTRY-1" || tx.TxCurrency != "CHF" {
+		t.Errorf("got reference %q in %s, want the entry reference in CHF", tx.ExternalID, tx.TxCurrency)
+	}
+}
+
+func TestCAMTSigned(t *testing.T) {
+	tests := []struct {
+		value string
+		sign  string
+		want  float64
+	}{
+		{"12.50", "CRDT", 12.50},
+		{"12.50", "DBIT", -12.50},
+		{" 0.99 ", "DBIT", -0.99},
+		{"12.50", "", 12.50},
+	}
+	for _, tt := range tests {
+		got, err := camtSigned(camtAmount{Value: tt.value}, tt.sign)
+		if err != nil || got != tt.want {
+			t.Errorf("%q %s: got %.2f, %v, want %.2f", tt.value, tt.sign, got, err, tt.want)
+		}
+	}
+
+	if _, err := camtSigned(camtAmount{Value: "1,50"}, "CRDT"); err == nil {
+		t.Error("parsed a decimal comma, camt amounts use a point")
+	}
+}
@@ -3,66 +3,145 @@ package parser
 import (
 	"encoding/csv"
 	"fmt"
+	"io"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
+	"null-statement-parser/internal/alias"
 	"null-statement-parser/internal/domain"
 )
 
-type CSVParser struct{}
+type CSVParser struct {
+	// Profile is the export layout, detected from the file when nil
+	Profile *CSVProfile
+	// AccountNumber and AccountType override the profile's fixed account
+	AccountNumber string
+	AccountType   string
+}
 
 func NewCSVParser() *CSVParser {
 	return &CSVParser{}
 }
 
-// ParseCSV parses RBC CSV export file
-// CSV format: "Account Type","Account Number","Transaction Date","Cheque Number","Description 1","Description 2","CAD$","USD$"
-func (p *CSVParser) ParseCSV(csvPath string) ([]*domain.Transaction, error) {
-	file, err := os.Open(csvPath)
+func newCSVReader(r io.Reader) *csv.Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1 // Allow variable number of fields
+	reader.TrimLeadingSpace = true
+	return reader
+}
+
+// headerSearchRows is how far down a file the header row is looked for
+const headerSearchRows = 10
+
+// DetectCSVProfile picks the built-in profile whose header appears in the
+// file, or failing that the first headerless profile that parses its rows
+func (p *CSVParser) DetectCSVProfile(csvPath string) (*CSVProfile, error) {
+	data, err := os.ReadFile(csvPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open CSV file: %w", err)
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	reader.FieldsPerRecord = -1 // Allow variable number of fields
-	reader.TrimLeadingSpace = true
+	for _, builtin := range builtinCSVProfiles {
+		records, err := builtin.readRecords(data)
+		if err != nil {
+			continue
+		}
+
+		if !builtin.NoHeader {
+			for i, record := range records[:min(len(records), headerSearchRows)] {
+				if newColumnIndex(record).hasAll(builtin.headerColumns()) {
+					profile := *builtin
+					profile.SkipRows = i
+					return &profile, nil
+				}
+			}
+			continue
+		}
+
+		rows := records[min(builtin.SkipRows, len(records)):]
+		rows = rows[:min(len(rows), 3)]
+		if len(rows) == 0 {
+			continue
+		}
+		probe := &CSVParser{Profile: builtin, AccountNumber: "probe"}
+		matched := true
+		for _, record := range rows {
+			if _, err := probe.parseCSVRow(record, columnIndex{}, csvPath); err != nil {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			profile := *builtin
+			return &profile, nil
+		}
+	}
+
+	return nil, fmt.Errorf("could not recognise the CSV layout, pick a profile (%s) or write one", strings.Join(CSVProfileNames(), ", "))
+}
+
+// ParseCSV parses a bank CSV export using p.Profile, detecting the layout
+// first when none is set. The default RBC format is
+// "Account Type","Account Number","Transaction Date","Cheque Number","Description 1","Description 2","CAD$","USD$"
+func (p *CSVParser) ParseCSV(csvPath string) ([]*domain.Transaction, error) {
+	profile := p.Profile
+	if profile == nil {
+		detected, err := p.DetectCSVProfile(csvPath)
+		if err != nil {
+			return nil, err
+		}
+		profile = detected
+	}
+	parser := *p
+	parser.Profile = profile
+
+	data, err := os.ReadFile(csvPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
 
 	// Read all records
-	records, err := reader.ReadAll()
+	records, err := profile.readRecords(data)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CSV: %w", err)
 	}
 
-	if len(records) < 2 {
-		return nil, fmt.Errorf("CSV file is empty or has no data rows")
+	firstRow := profile.SkipRows
+	colIndices := columnIndex{}
+	if !profile.NoHeader {
+		if len(records) <= profile.SkipRows {
+			return nil, fmt.Errorf("CSV file is empty or has no header")
+		}
+		// Parse header to find column indices
+		colIndices = newColumnIndex(records[profile.SkipRows])
+		for _, col := range profile.headerColumns() {
+			if _, ok := colIndices.lookup(col); !ok {
+				return nil, fmt.Errorf("missing required column: %s", col)
+			}
+		}
+		firstRow++
 	}
 
-	// Parse header to find column indices
-	header := records[0]
-	colIndices := make(map[string]int)
-	for i, col := range header {
-		colIndices[col] = i
+	if len(records) <= firstRow {
+		return nil, fmt.Errorf("CSV file is empty or has no data rows")
 	}
 
-	// Validate required columns
-	requiredCols := []string{"Account Type", "Account Number", "Transaction Date", "Description 1", "CAD$", "USD$"}
-	for _, col := range requiredCols {
-		if _, ok := colIndices[col]; !ok {
-			return nil, fmt.Errorf("missing required column: %s", col)
-		}
+	if profile.Columns.AccountNumber == "" && parser.accountNumber() == "" {
+		return nil, fmt.Errorf("CSV profile %s has no account number column, set one for this export", profile.Name)
 	}
 
 	var transactions []*domain.Transaction
 
 	// Parse each row (skip header)
-	for i, record := range records[1:] {
-		tx, err := p.parseCSVRow(record, colIndices, csvPath)
+	for i, record := range records[firstRow:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		tx, err := parser.parseCSVRow(record, colIndices, csvPath)
 		if err != nil {
 			// Skip malformed rows with a warning
-			fmt.Printf("Warning: skipping row %d: %v\n", i+2, err)
+			fmt.Fprintf(os.Stderr, "Warning: skipping row %d: %v\n", firstRow+i+1, err)
 			continue
 		}
 		if tx != nil {
@@ -73,65 +152,154 @@ func (p *CSVParser) ParseCSV(csvPath string) ([]*domain.Transaction, error) {
 	return transactions, nil
 }
 
-func (p *CSVParser) parseCSVRow(record []string, colIndices map[string]int, sourcePath string) (*domain.Transaction, error) {
+func (p *CSVParser) accountNumber() string {
+	if p.AccountNumber != "" {
+		return p.AccountNumber
+	}
+	return p.Profile.AccountNumber
+}
+
+func (p *CSVParser) accountType() string {
+	if p.AccountType != "" {
+		return p.AccountType
+	}
+	return p.Profile.AccountType
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *CSVParser) parseCSVRow(record []string, colIndices columnIndex, sourcePath string) (*domain.Transaction, error) {
+	profile := p.Profile
+	cols := profile.Columns
+
 	// Helper to safely get column value
-	getCol := func(name string) string {
-		if idx, ok := colIndices[name]; ok && idx < len(record) {
+	getCol := func(ref string) string {
+		if ref == "" {
+			return ""
+		}
+		if idx, ok := colIndices.lookup(ref); ok && idx < len(record) {
 			return strings.TrimSpace(record[idx])
 		}
 		return ""
 	}
 
-	// Parse account type
-	accountType := strings.ToLower(getCol("Account Type"))
-	if accountType == "" {
-		return nil, fmt.Errorf("empty account type")
+	if !profile.keepsActivity(getCol(cols.Activity)) {
+		return nil, nil
 	}
 
-	// Parse account number
-	accountNumber := getCol("Account Number")
+	// Parse account number, BMO quotes card numbers with a leading apostrophe
+	accountNumber := p.AccountNumber
+	if accountNumber == "" {
+		accountNumber = strings.Trim(getCol(cols.AccountNumber), "'\" ")
+	}
+	if accountNumber == "" {
+		accountNumber = profile.AccountNumber
+	}
 	if accountNumber == "" {
 		return nil, fmt.Errorf("empty account number")
 	}
 
-	// Parse transaction date (format: M/D/YYYY or MM/DD/YYYY)
-	dateStr := getCol("Transaction Date")
-	txDate, err := time.Parse("1/2/2006", dateStr)
+	accountType := strings.ToLower(getCol(cols.AccountType))
+	if accountType == "" && p.AccountType == "" && profile.MaskedAccountType != "" && alias.Masked(accountNumber) {
+		accountType = profile.MaskedAccountType
+	}
+	if accountType == "" {
+		accountType = p.accountType()
+	}
+	if accountType == "" {
+		return nil, fmt.Errorf("empty account type")
+	}
+
+	dateStr := getCol(cols.Date)
+	txDate, err := parseCSVDate(dateStr, profile.DateFormats)
 	if err != nil {
-		return nil, fmt.Errorf("invalid date format: %s", dateStr)
+		return nil, err
+	}
+
+	var postingDate time.Time
+	if s := getCol(cols.PostingDate); s != "" {
+		if postingDate, err = parseCSVDate(s, profile.DateFormats); err != nil {
+			return nil, err
+		}
 	}
 
 	// Parse descriptions
-	desc1 := getCol("Description 1")
-	desc2 := getCol("Description 2")
-	description := strings.TrimSpace(desc1 + " " + desc2)
+	var parts []string
+	for _, col := range cols.Description {
+		if part := getCol(col); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	description := strings.Join(parts, " ")
 	if description == "" {
 		return nil, fmt.Errorf("empty description")
 	}
 
-	// Parse amount (CAD$ or USD$)
-	cadStr := getCol("CAD$")
-	usdStr := getCol("USD$")
+	currency := getCol(cols.Currency)
+	if currency == "" {
+		currency = profile.Currency
+	}
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
 
+	// amount is signed, negative is money out
 	var amount float64
-	var currency string
+	switch {
+	case len(cols.CurrencyAmounts) > 0:
+		found := false
+		for _, ca := range cols.CurrencyAmounts {
+			if s := getCol(ca.Column); s != "" {
+				if amount, err = parseAmount(s, profile.DecimalComma); err != nil {
+					return nil, fmt.Errorf("invalid %s amount: %s", ca.Currency, s)
+				}
+				currency = ca.Currency
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("no amount specified")
+		}
 
-	if cadStr != "" {
-		cadStr = strings.ReplaceAll(cadStr, ",", "")
-		amount, err = strconv.ParseFloat(cadStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid CAD amount: %s", cadStr)
+	case cols.Amount != "":
+		s := getCol(cols.Amount)
+		if s == "" {
+			return nil, fmt.Errorf("no amount specified")
 		}
-		currency = "CAD"
-	} else if usdStr != "" {
-		usdStr = strings.ReplaceAll(usdStr, ",", "")
-		amount, err = strconv.ParseFloat(usdStr, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid USD amount: %s", usdStr)
+		if amount, err = parseAmount(s, profile.DecimalComma); err != nil {
+			return nil, err
+		}
+		if profile.Sign == SignPositiveOut {
+			amount = -amount
+		}
+
+	default:
+		debit, credit := getCol(cols.Debit), getCol(cols.Credit)
+		if debit == "" && credit == "" {
+			return nil, fmt.Errorf("no amount specified")
+		}
+		if debit != "" {
+			value, err := parseAmount(debit, profile.DecimalComma)
+			if err != nil {
+				return nil, err
+			}
+			amount -= value
+		}
+		if credit != "" {
+			value, err := parseAmount(credit, profile.DecimalComma)
+			if err != nil {
+				return nil, err
+			}
+			amount += value
 		}
-		currency = "USD"
-	} else {
-		return nil, fmt.Errorf("no amount specified")
 	}
 
 	if amount == 0 {
@@ -149,93 +317,25 @@ func (p *CSVParser) parseCSVRow(record []string, colIndices map[string]int, sour
 
 	return &domain.Transaction{
 		TxDate:                 txDate,
+		PostingDate:            postingDate,
 		TxAmount:               amount,
-		TxCurrency:             currency,
+		TxCurrency:             strings.ToUpper(currency),
 		TxDirection:            direction,
 		TxDesc:                 description,
+		UserNotes:              getCol(cols.Notes),
 		StatementAccountNumber: &accountNumber,
+		Source:                 domain.SourceCSV,
 		StatementAccountType:   accountType,
+		StatementBank:          profile.Bank,
 		SourceFilePath:         sourcePath,
 	}, nil
 }
 
-func GetLast4Digits(accountNumber string) string {
-	if len(accountNumber) >= 4 {
-		return accountNumber[len(accountNumber)-4:]
-	}
-	return accountNumber
-}
-
-func MatchesAccount(tx *domain.Transaction, last4 string) bool {
-	if tx.StatementAccountNumber == nil {
-		return false
-	}
-	accountNum := *tx.StatementAccountNumber
-	return strings.HasSuffix(accountNum, last4) || accountNum == last4
-}
-
-func FindLatestTransactionDate(transactions []*domain.Transaction, accountLast4 string) *time.Time {
-	var latest *time.Time
-
-	for _, tx := range transactions {
-		if MatchesAccount(tx, accountLast4) {
-			if latest == nil || tx.TxDate.After(*latest) {
-				latest = &tx.TxDate
-			}
-		}
-	}
-
-	return latest
-}
-
-func MergeCSVWithStatements(statementTxs []*domain.Transaction, csvTxs []*domain.Transaction) []*domain.Transaction {
-	// Group CSV transactions by account (last 4 digits)
-	csvByAccount := make(map[string][]*domain.Transaction)
-	for _, tx := range csvTxs {
-		if tx.StatementAccountNumber == nil {
-			continue
+func parseCSVDate(s string, layouts []string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
 		}
-		last4 := GetLast4Digits(*tx.StatementAccountNumber)
-		csvByAccount[last4] = append(csvByAccount[last4], tx)
 	}
-
-	// Find cutoff dates for each account
-	cutoffDates := make(map[string]*time.Time)
-	for last4 := range csvByAccount {
-		cutoffDates[last4] = FindLatestTransactionDate(statementTxs, last4)
-	}
-
-	// Build a map from last4 -> statement account number for normalization
-	statementAccountByLast4 := make(map[string]string)
-	for _, tx := range statementTxs {
-		if tx.StatementAccountNumber == nil {
-			continue
-		}
-		last4 := GetLast4Digits(*tx.StatementAccountNumber)
-		statementAccountByLast4[last4] = *tx.StatementAccountNumber
-	}
-
-	// Filter CSV transactions to only include those after cutoff
-	var newTransactions []*domain.Transaction
-	for last4, txs := range csvByAccount {
-		cutoff := cutoffDates[last4]
-
-		for _, tx := range txs {
-			// Include if no cutoff date (no statement for this account) or after cutoff
-			if cutoff == nil || tx.TxDate.After(*cutoff) {
-				// Normalize account number to match the statement format
-				if statementAccNum, ok := statementAccountByLast4[last4]; ok {
-					tx.StatementAccountNumber = &statementAccNum
-				}
-				newTransactions = append(newTransactions, tx)
-			}
-		}
-	}
-
-	// Combine statement transactions with new CSV transactions
-	result := make([]*domain.Transaction, 0, len(statementTxs)+len(newTransactions))
-	result = append(result, statementTxs...)
-	result = append(result, newTransactions...)
-
-	return result
+	return time.Time{}, fmt.Errorf("invalid date format: %s", s)
 }
@@ -0,0 +1,145 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestDetectAndParseCSV(t *testing.T) {
+	tests := []struct {
+		file     string
+		profile  string
+		skipRows int
+		account  string
+		want     []wantTx
+	}{
+		{
+			file:    "rbc.csv",
+			profile: "rbc",
+			want: []wantTx{
+				{"2025-01-15", 4.50, domain.Out, "TIM HORTONS #1234", "05172-5163878", "chequing"},
+				{"2025-01-16", 1500, domain.In, "PAYROLL ACME CORP", "05172-5163878", "chequing"},
+				{"2025-01-17", 12, domain.Out, "AMAZON.COM", "4510123412341234", "visa"},
+			},
+		},
+		{
+			// the header sits below a preamble
+			file:     "bmo.csv",
+			profile:  "bmo",
+			skipRows: 1,
+			want: []wantTx{
+				{"2025-01-15", 4.50, domain.Out, "[CW]TIM HORTONS", "5191230000001234", "chequing"},
+				{"2025-01-16", 1500, domain.In, "[DN]PAYROLL", "5191230000001234", "chequing"},
+			},
+		},
+		{
+			// charges are positive
+			file:    "amex.csv",
+			profile: "amex",
+			account: "1001",
+			want: []wantTx{
+				{"2025-01-15", 25.40, domain.Out, "UBER EATS", "1001", "credit_card"},
+				{"2025-01-20", 100, domain.In, "PAYMENT RECEIVED - THANK YOU", "1001", "credit_card"},
+			},
+		},
+		{
+			// trades are dropped
+			file:    "rbc-di.csv",
+			profile: "rbc-di",
+			want: []wantTx{
+				{"2025-01-15", 500, domain.In, "Contribution CONTRIBUTION", "12345678", "investment"},
+				{"2025-01-31", 1.25, domain.In, "Dividend ISHARES CORE EQUITY ETF", "12345678", "investment"},
+			},
+		},
+		{
+			file:    "td.csv",
+			profile: "td",
+			account: "6001234",
+			want: []wantTx{
+				{"2025-01-15", 4.50, domain.Out, "TIM HORTONS #1234", "6001234", "chequing"},
+				{"2025-01-16", 1500, domain.In, "PAYROLL", "6001234", "chequing"},
+			},
+		},
+		{
+			// a masked card number is a credit card
+			file:    "cibc.csv",
+			profile: "cibc",
+			want: []wantTx{
+				{"2025-01-15", 4.50, domain.Out, "TIM HORTONS", "4500********1234", "credit_card"},
+				{"2025-01-16", 100, domain.In, "PAYMENT THANK YOU", "4500********1234", "credit_card"},
+			},
+		},
+		{
+			file:    "cibc-chequing.csv",
+			profile: "cibc",
+			account: "0012345",
+			want: []wantTx{
+				{"2025-01-15", 50, domain.Out, "E-TRANSFER JOHN DOE", "0012345", "chequing"},
+				{"2025-01-16", 1500, domain.In, "PAYROLL", "0012345", "chequing"},
+			},
+		},
+		{
+			file:    "scotiabank.csv",
+			profile: "scotiabank",
+			account: "8001234",
+			want: []wantTx{
+				{"2025-01-15", 4.50, domain.Out, "Debit card purchase TIM HORTONS", "8001234", "chequing"},
+				{"2025-01-16", 1500, domain.In, "Payroll deposit ACME CORP", "8001234", "chequing"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			path := filepath.Join("testdata", tt.file)
+			csvParser := &CSVParser{AccountNumber: tt.account}
+
+			profile, err := csvParser.DetectCSVProfile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if profile.Name != tt.profile || profile.SkipRows != tt.skipRows {
+				t.Fatalf("detected %s skipping %d rows, want %s skipping %d", profile.Name, profile.SkipRows, tt.profile, tt.skipRows)
+			}
+
+			csvParser.Profile = profile
+			transactions, err := csvParser.ParseCSV(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkTransactions(t, transactions, tt.want)
+		})
+	}
+}
+
+func TestParseCSVWithProfileFile(t *testing.T) {
+	profile, err := LoadCSVProfile(filepath.Join("testdata", "mybank.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transactions, err := (&CSVParser{Profile: profile}).ParseCSV(filepath.Join("testdata", "mybank.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTransactions(t, transactions, []wantTx{
+		{"2025-01-15", 1234.56, domain.Out, "Café Olé Card 1234", "1234", "chequing"},
+		{"2025-01-16", 2000, domain.In, "ACME Salary", "1234", "chequing"},
+	})
+	if transactions[0].StatementBank != "My Bank" {
+		t.Errorf("got bank %q, want My Bank", transactions[0].StatementBank)
+	}
+}
+
+func TestDetectCSVProfileRejectsUnknownLayouts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "other.csv")
+	if err := os.WriteFile(path, []byte("When,What,How much\n2025-01-15,coffee,4.50\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := NewCSVParser().DetectCSVProfile(path); err == nil {
+		t.Fatalf("detected %s for an unknown layout", profile.Name)
+	}
+}
@@ -0,0 +1,27 @@
+package parser
+
+import "null-statement-parser/internal/domain"
+
+// Deduplicate drops transactions whose bank reference or alert email was
+// already seen in the same account, e.g. when the same statement is in the
+// input folder twice.
+// Transactions without either are kept, since identical lines can be genuine.
+func Deduplicate(transactions []*domain.Transaction) ([]*domain.Transaction, int) {
+	seen := make(map[string]bool)
+	result := make([]*domain.Transaction, 0, len(transactions))
+	dropped := 0
+
+	for _, tx := range transactions {
+		if tx.ExternalID != "" || tx.EmailID != "" {
+			key := tx.DedupKey()
+			if seen[key] {
+				dropped++
+				continue
+			}
+			seen[key] = true
+		}
+		result = append(result, tx)
+	}
+
+	return result, dropped
+}
@@ -0,0 +1,32 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestDeduplicateScopesReferencesToAccount(t *testing.T) {
+	tx := func(number, accountType, ref string) *domain.Transaction {
+		return &domain.Transaction{
+			StatementAccountNumber: &number,
+			StatementAccountType:   accountType,
+			ExternalID:             ref,
+			TxDate:                 time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC),
+			TxAmount:               10,
+		}
+	}
+
+	transactions := []*domain.Transaction{
+		tx("5163878", "chequing", "REF1"),
+		tx("5163878", "chequing", "REF1"),
+		tx("9988776", "chequing", "REF1"),
+		tx("5163878", "savings", "REF1"),
+	}
+
+	result, dropped := Deduplicate(transactions)
+	if dropped != 1 || len(result) != 3 {
+		t.Fatalf("got %d kept, %d dropped, want 3 kept, 1 dropped", len(result), dropped)
+	}
+}
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"null-statement-parser/internal/domain"
+)
+
+// Statement file formats handled by ParseFile
+const (
+	FormatQIF   = "qif"
+	FormatCAMT  = "camt"
+	FormatMT940 = "mt940"
+)
+
+// fileFormats maps file extensions onto formats
+var fileFormats = map[string]string{
+	".qif":   FormatQIF,
+	".sta":   FormatMT940,
+	".mt940": FormatMT940,
+	".940":   FormatMT940,
+}
+
+// DetectFileFormat picks the format of a statement file from its extension,
+// looking inside .xml files for a camt message and .txt files for MT940 tags
+func DetectFileFormat(path string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(path))
+	if format, ok := fileFormats[ext]; ok {
+		return format, nil
+	}
+
+	if ext == ".xml" {
+		head, err := readHead(path, 4096)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(head, "camt.05") || strings.Contains(head, "BkToCstmr") {
+			return FormatCAMT, nil
+		}
+	}
+
+	if ext == ".txt" {
+		head, err := readHead(path, 4096)
+		if err != nil {
+			return "", err
+		}
+		if strings.Contains(head, ":20:") && strings.Contains(head, ":25:") {
+			return FormatMT940, nil
+		}
+	}
+
+	return "", fmt.Errorf("%s: unrecognised statement file", path)
+}
+
+func readHead(path string, n int) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	buf := make([]byte, n)
+	read, err := io.ReadFull(file, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return string(buf[:read]), nil
+}
+
+// FindStatementFiles lists the recognised statement files at path, which may
+// be a single file or a directory (not searched recursively)
+func FindStatementFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var files []string
+	for _, entry := range entries {
+		file := filepath.Join(path, entry.Name())
+		if _, err := DetectFileFormat(file); err == nil && !entry.IsDir() {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// ParseFile parses one statement file in any format other than PDF and CSV,
+// which have their own parsers. Formats without balances return no
+// statements. accountType is used for MT940, which doesn't name one.
+func ParseFile(path, accountType string) ([]*domain.Transaction, []*domain.Statement, error) {
+	format, err := DetectFileFormat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch format {
+	case FormatQIF:
+		transactions, err := ParseQIF(path)
+		return transactions, nil, err
+	case FormatCAMT:
+		return ParseCAMT(path)
+	case FormatMT940:
+		return ParseMT940(path, accountType)
+	default:
+		return nil, nil, fmt.Errorf("%s: unsupported format %s", path, format)
+	}
+}
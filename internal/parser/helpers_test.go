@@ -0,0 +1,40 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// wantTx is the part of a parsed transaction the parser tests compare
+type wantTx struct {
+	date        string
+	amount      float64
+	direction   domain.Direction
+	desc        string
+	account     string
+	accountType string
+}
+
+func checkTransactions(t *testing.T, got []*domain.Transaction, want []wantTx) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d transactions, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		tx := got[i]
+		if date := tx.TxDate.Format(time.DateOnly); date != w.date {
+			t.Errorf("%d: got date %s, want %s", i, date, w.date)
+		}
+		if tx.TxAmount != w.amount || tx.TxDirection != w.direction {
+			t.Errorf("%d: got %.2f %s, want %.2f %s", i, tx.TxAmount, tx.TxDirection, w.amount, w.direction)
+		}
+		if tx.TxDesc != w.desc {
+			t.Errorf("%d: got description %q, want %q", i, tx.TxDesc, w.desc)
+		}
+		if *tx.StatementAccountNumber != w.account || tx.StatementAccountType != w.accountType {
+			t.Errorf("%d: got account %s %s, want %s %s", i, *tx.StatementAccountNumber, tx.StatementAccountType, w.account, w.accountType)
+		}
+	}
+}
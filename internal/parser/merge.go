@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/alias"
+	"null-statement-parser/internal/domain"
+)
+
+// AccountMerge is what MergeCSVWithStatements did with the rows of one
+// provisional account
+type AccountMerge struct {
+	Account string `json:"account"`
+	Type    string `json:"type,omitempty"`
+	// Matched is the statement account the rows were renumbered to
+	Matched string     `json:"matched,omitempty"`
+	Cutoff  *time.Time `json:"cutoff,omitempty"`
+	Kept    int        `json:"kept"`
+	Dropped int        `json:"dropped"`
+	// Candidates lists the statement accounts the rows could belong to when
+	// more than one fits, in which case nothing is renumbered or dropped
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// Ambiguous reports whether the rows matched more than one statement account
+func (m AccountMerge) Ambiguous() bool {
+	return len(m.Candidates) > 0
+}
+
+// mergeAccount is an account seen in the transactions being merged, keyed by
+// its kind and canonical number
+type mergeAccount struct {
+	number string
+	kind   string
+	latest *time.Time
+	txs    []*domain.Transaction
+}
+
+func (a *mergeAccount) key() string {
+	return accountKey(a.kind, a.number)
+}
+
+// AccountKey identifies an account by the kind of its type and its canonical
+// number, so a card and a bank account with the same number stay apart
+func AccountKey(number, accountType string) string {
+	return accountKey(accountKind(accountType), number)
+}
+
+func accountKey(kind, number string) string {
+	return kind + "|" + alias.Canonical(number)
+}
+
+// accountKind groups account types that a number is shared within. A card and
+// a bank account can end in the same digits, a chequing and savings account
+// at one bank are told apart by the number.
+func accountKind(accountType string) string {
+	switch strings.ToLower(accountType) {
+	case "visa", "mastercard", "amex", "credit_card":
+		return "credit_card"
+	case "chequing", "savings":
+		return "bank"
+	case "investment", "rrsp", "tfsa":
+		return "investment"
+	default:
+		return ""
+	}
+}
+
+// compatibleKind is true when the kinds are the same or one is unknown
+func compatibleKind(a, b string) bool {
+	return a == b || a == "" || b == ""
+}
+
+// fullNumbers returns the forms of a number that say more than its last 4
+// digits
+func fullNumbers(number string) map[string]bool {
+	forms := make(map[string]bool)
+	for _, v := range alias.Variants(number) {
+		if len(alias.Digits(v)) > 4 && !alias.Masked(v) {
+			forms[strings.ToLower(v)] = true
+		}
+	}
+	return forms
+}
+
+func sameNumber(a, b string) bool {
+	forms := fullNumbers(a)
+	for form := range fullNumbers(b) {
+		if forms[form] {
+			return true
+		}
+	}
+	return false
+}
+
+// groupByAccount splits transactions by account kind and number, in order of
+// first appearance. Transactions without a number are returned apart.
+func groupByAccount(transactions []*domain.Transaction) ([]*mergeAccount, []*domain.Transaction) {
+	var accounts []*mergeAccount
+	var unnumbered []*domain.Transaction
+	byKey := make(map[string]*mergeAccount)
+
+	for _, tx := range transactions {
+		if tx.StatementAccountNumber == nil || *tx.StatementAccountNumber == "" {
+			unnumbered = append(unnumbered, tx)
+			continue
+		}
+		a := &mergeAccount{number: *tx.StatementAccountNumber, kind: accountKind(tx.StatementAccountType)}
+		if existing, ok := byKey[a.key()]; ok {
+			a = existing
+		} else {
+			byKey[a.key()] = a
+			accounts = append(accounts, a)
+		}
+
+		a.txs = append(a.txs, tx)
+		if a.latest == nil || tx.TxDate.After(*a.latest) {
+			date := tx.TxDate
+			a.latest = &date
+		}
+	}
+	return accounts, unnumbered
+}
+
+// matchStatementAccount finds the statement accounts provisional rows belong
+// to: those with the same full number, else those ending in the same 4
+// digits, always of a compatible kind
+func matchStatementAccount(a *mergeAccount, statementAccounts []*mergeAccount) []*mergeAccount {
+	var full, last4 []*mergeAccount
+	for _, s := range statementAccounts {
+		if !compatibleKind(a.kind, s.kind) {
+			continue
+		}
+		switch {
+		case sameNumber(a.number, s.number):
+			full = append(full, s)
+		case alias.Last4(a.number) == alias.Last4(s.number):
+			last4 = append(last4, s)
+		}
+	}
+	if len(full) > 0 {
+		return full
+	}
+	return last4
+}
+
+// MergeCSVWithStatements adds the provisional rows (CSV lines or alert emails)
+// that are newer than the statements of their account. Rows are matched to a
+// statement account by type and number, renumbered to it, and dropped when
+// dated on or before its latest statement line. Rows that match several
+// statement accounts are kept under their own number and reported with the
+// candidates. Rows without an account number are dropped.
+func MergeCSVWithStatements(statementTxs []*domain.Transaction, csvTxs []*domain.Transaction) ([]*domain.Transaction, []AccountMerge) {
+	statementAccounts, _ := groupByAccount(statementTxs)
+	csvAccounts, unnumbered := groupByAccount(csvTxs)
+
+	var merges []AccountMerge
+	var newTransactions []*domain.Transaction
+	for _, a := range csvAccounts {
+		merge := AccountMerge{Account: a.number, Type: a.txs[0].StatementAccountType}
+
+		var matched *mergeAccount
+		switch candidates := matchStatementAccount(a, statementAccounts); len(candidates) {
+		case 0:
+		case 1:
+			matched = candidates[0]
+			merge.Matched = matched.number
+			merge.Cutoff = matched.latest
+		default:
+			for _, c := range candidates {
+				merge.Candidates = append(merge.Candidates, c.number)
+			}
+		}
+
+		for _, tx := range a.txs {
+			if matched != nil && !tx.TxDate.After(*matched.latest) {
+				merge.Dropped++
+				continue
+			}
+			if matched != nil {
+				number := matched.number
+				tx.StatementAccountNumber = &number
+			}
+			merge.Kept++
+			newTransactions = append(newTransactions, tx)
+		}
+		merges = append(merges, merge)
+	}
+	if len(unnumbered) > 0 {
+		merges = append(merges, AccountMerge{Dropped: len(unnumbered)})
+	}
+
+	result := make([]*domain.Transaction, 0, len(statementTxs)+len(newTransactions))
+	result = append(result, statementTxs...)
+	result = append(result, newTransactions...)
+
+	return result, merges
+}
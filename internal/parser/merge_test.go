@@ -0,0 +1,121 @@
+package parser
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func mergeTx(number, accountType, date string) *domain.Transaction {
+	d, _ := time.Parse(time.DateOnly, date)
+	tx := &domain.Transaction{StatementAccountType: accountType, TxDate: d, TxAmount: 10}
+	if number != "" {
+		tx.StatementAccountNumber = &number
+	}
+	return tx
+}
+
+func TestGroupByAccount(t *testing.T) {
+	accounts, unnumbered := groupByAccount([]*domain.Transaction{
+		mergeTx("05172-5163878", "chequing", "2025-01-10"),
+		mergeTx("051725163878", "savings", "2025-01-20"),
+		mergeTx("051725163878", "visa", "2025-01-15"),
+		mergeTx("", "chequing", "2025-01-12"),
+	})
+
+	// chequing and savings share a kind, the card doesn't
+	if len(accounts) != 2 || len(unnumbered) != 1 {
+		t.Fatalf("got %d accounts and %d unnumbered, want 2 and 1", len(accounts), len(unnumbered))
+	}
+	bank, card := accounts[0], accounts[1]
+	if bank.kind != "bank" || len(bank.txs) != 2 || bank.latest.Format(time.DateOnly) != "2025-01-20" {
+		t.Errorf("got %s account with %d transactions up to %s", bank.kind, len(bank.txs), bank.latest)
+	}
+	if card.kind != "credit_card" || len(card.txs) != 1 {
+		t.Errorf("got %s account with %d transactions", card.kind, len(card.txs))
+	}
+}
+
+func TestMergeCSVWithStatements(t *testing.T) {
+	tests := []struct {
+		name       string
+		statements []*domain.Transaction
+		csv        []*domain.Transaction
+		matched    string
+		candidates []string
+		kept       int
+		dropped    int
+		account    string
+	}{
+		{
+			name:       "full number in another form",
+			statements: []*domain.Transaction{mergeTx("05172-5163878", "chequing", "2025-01-31")},
+			csv: []*domain.Transaction{
+				mergeTx("5163878", "chequing", "2025-01-31"),
+				mergeTx("5163878", "chequing", "2025-02-02"),
+			},
+			matched: "05172-5163878",
+			kept:    1,
+			dropped: 1,
+			account: "05172-5163878",
+		},
+		{
+			name:       "last 4 of a compatible kind",
+			statements: []*domain.Transaction{mergeTx("4510123412341234", "visa", "2025-01-31")},
+			csv:        []*domain.Transaction{mergeTx("****1234", "credit_card", "2025-02-02")},
+			matched:    "4510123412341234",
+			kept:       1,
+			account:    "4510123412341234",
+		},
+		{
+			// a card ending like the chequing account keeps its own number
+			name:       "kind mismatch",
+			statements: []*domain.Transaction{mergeTx("051725161234", "chequing", "2025-01-31")},
+			csv:        []*domain.Transaction{mergeTx("1234", "visa", "2025-01-15")},
+			kept:       1,
+			account:    "1234",
+		},
+		{
+			name: "last 4 of several accounts",
+			statements: []*domain.Transaction{
+				mergeTx("051725161234", "chequing", "2025-01-31"),
+				mergeTx("051729991234", "savings", "2025-01-31"),
+			},
+			csv:        []*domain.Transaction{mergeTx("1234", "chequing", "2025-01-15")},
+			candidates: []string{"051725161234", "051729991234"},
+			kept:       1,
+			account:    "1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, merges := MergeCSVWithStatements(tt.statements, tt.csv)
+			if len(merges) != 1 {
+				t.Fatalf("got %d merges, want 1", len(merges))
+			}
+			merge := merges[0]
+			if merge.Matched != tt.matched || !slices.Equal(merge.Candidates, tt.candidates) {
+				t.Errorf("got matched %q, candidates %v, want %q, %v", merge.Matched, merge.Candidates, tt.matched, tt.candidates)
+			}
+			if merge.Kept != tt.kept || merge.Dropped != tt.dropped {
+				t.Errorf("got %d kept, %d dropped, want %d, %d", merge.Kept, merge.Dropped, tt.kept, tt.dropped)
+			}
+			if len(result) != len(tt.statements)+tt.kept {
+				t.Fatalf("got %d transactions, want %d", len(result), len(tt.statements)+tt.kept)
+			}
+			if got := *result[len(result)-1].StatementAccountNumber; got != tt.account {
+				t.Errorf("got kept row under %s, want %s", got, tt.account)
+			}
+		})
+	}
+}
+
+func TestMergeCSVWithStatementsDropsUnnumbered(t *testing.T) {
+	result, merges := MergeCSVWithStatements(nil, []*domain.Transaction{mergeTx("", "chequing", "2025-01-15")})
+	if len(result) != 0 || len(merges) != 1 || merges[0].Dropped != 1 {
+		t.Fatalf("got %d transactions and merges %+v, want the row dropped", len(result), merges)
+	}
+}
@@ -0,0 +1,303 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+var (
+	mt940Tag = regexp.MustCompile(`^:(\d{2}[A-Z]?):`)
+	// value date, optional entry date, mark, optional funds code, amount,
+	// transaction type, customer reference, optional bank reference
+	mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?([\d,]+)([A-Z][A-Z0-9]{3})([^/\n]*?)(?://([^\n]*))?(?:\n(.*))?$`)
+	// :60F:, :62F: and friends
+	mt940Balance = regexp.MustCompile(`^(C|D)(\d{6})([A-Z]{3})([\d,]+)$`)
+	// structured :86: subfields as written by German banks, e.g. ?20...?32...
+	mt940Subfield = regexp.MustCompile(`\?(\d{2})`)
+)
+
+type mt940Field struct {
+	tag   string
+	value string
+}
+
+type mt940Statement struct {
+	reference string
+	account   string
+	number    string
+	fields    []mt940Field
+}
+
+// ParseMT940 parses a SWIFT MT940 file, which may hold several statements.
+// Each :61: line becomes a transaction described by the :86: that follows it,
+// and :60F:/:62F: give the statement's opening and closing balances. MT940
+// doesn't say what kind of account it is, accountType defaults to chequing.
+func ParseMT940(path, accountType string) ([]*domain.Transaction, []*domain.Statement, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open MT940 file: %w", err)
+	}
+	defer file.Close()
+
+	var statements []*mt940Statement
+	var current *mt940Statement
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		// SWIFT envelope blocks and the end-of-message marker
+		if line == "" || line == "-" || line == "-}" || strings.HasPrefix(line, "{") {
+			continue
+		}
+
+		match := mt940Tag.FindStringSubmatch(line)
+		if match == nil {
+			// continuation of the previous field
+			if current != nil && len(current.fields) > 0 {
+				field := &current.fields[len(current.fields)-1]
+				field.value += "\n" + line
+			}
+			continue
+		}
+
+		tag, value := match[1], strings.TrimSpace(line[len(match[0]):])
+		if tag == "20" {
+			current = &mt940Statement{reference: value}
+			statements = append(statements, current)
+			continue
+		}
+		if current == nil {
+			return nil, nil, fmt.Errorf("%s: :%s: before the first :20:", path, tag)
+		}
+		switch tag {
+		case "25":
+			current.account = value
+		case "28C":
+			current.number = value
+		}
+		current.fields = append(current.fields, mt940Field{tag: tag, value: value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to read MT940 file: %w", err)
+	}
+	if len(statements) == 0 {
+		return nil, nil, fmt.Errorf("%s: no :20: statements", path)
+	}
+
+	if accountType == "" {
+		accountType = "chequing"
+	}
+
+	var transactions []*domain.Transaction
+	var result []*domain.Statement
+	for _, stmt := range statements {
+		stmtTxs, statement, err := stmt.convert(path, accountType)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: statement %s %s: %w", path, stmt.reference, stmt.number, err)
+		}
+		transactions = append(transactions, stmtTxs...)
+		result = append(result, statement)
+	}
+	return transactions, result, nil
+}
+
+func (s *mt940Statement) convert(sourcePath, accountType string) ([]*domain.Transaction, *domain.Statement, error) {
+	if s.account == "" {
+		return nil, nil, fmt.Errorf("no :25: account")
+	}
+
+	statement := &domain.Statement{
+		SourceFilePath: sourcePath,
+		AccountNumber:  s.account,
+		AccountType:    accountType,
+	}
+	currency := ""
+
+	var transactions []*domain.Transaction
+	var last *domain.Transaction
+	for _, field := range s.fields {
+		switch field.tag {
+		case "60F", "60M":
+			amount, date, ccy, err := parseMT940Balance(field.value)
+			if err != nil {
+				return nil, nil, err
+			}
+			// only the first opening balance of a multi-page statement counts
+			if statement.OpeningBalance == nil {
+				statement.OpeningBalance = &amount
+				statement.PeriodStart = date
+			}
+			currency = ccy
+			statement.Currency = ccy
+
+		case "62F", "62M":
+			amount, date, _, err := parseMT940Balance(field.value)
+			if err != nil {
+				return nil, nil, err
+			}
+			statement.ClosingBalance = &amount
+			statement.PeriodEnd = date
+			last = nil
+
+		case "61":
+			tx, err := parseMT940Line(field.value, currency)
+			if err != nil {
+				return nil, nil, err
+			}
+			accountNumber := s.account
+			tx.StatementAccountNumber = &accountNumber
+			tx.StatementAccountType = statement.AccountType
+			tx.SourceFilePath = sourcePath
+			transactions = append(transactions, tx)
+			last = tx
+
+		case "86":
+			if last != nil {
+				applyMT940Narrative(last, field.value)
+				last = nil
+			}
+		}
+	}
+
+	return transactions, statement, nil
+}
+
+func parseMT940Line(value, currency string) (*domain.Transaction, error) {
+	m := mt940Line.FindStringSubmatch(value)
+	if m == nil {
+		return nil, fmt.Errorf("invalid :61: line: %s", value)
+	}
+
+	valueDate, err := time.Parse("060102", m[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid :61: value date: %s", m[1])
+	}
+	// the entry date is when the bank booked it, the value date when the
+	// money moved
+	entryDate := valueDate
+	if m[2] != "" {
+		entryDate, err = mt940EntryDate(m[2], valueDate)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	amount, err := parseAmount(m[5], true)
+	if err != nil {
+		return nil, err
+	}
+
+	// RC reverses a credit and RD a debit
+	direction := domain.In
+	if m[3] == "D" || m[3] == "RC" {
+		direction = domain.Out
+	}
+
+	tx := &domain.Transaction{
+		TxDate:      valueDate,
+		PostingDate: entryDate,
+		TxAmount:    amount,
+		TxCurrency:  currency,
+		TxDirection: direction,
+		Source:      domain.SourceMT940,
+	}
+
+	if bankRef := strings.TrimSpace(m[8]); bankRef != "" {
+		tx.ExternalID = bankRef
+	}
+
+	// usually replaced by the :86: narrative
+	tx.TxDesc = m[6]
+	if details := strings.TrimSpace(m[9]); details != "" {
+		tx.TxDesc = details
+	} else if customerRef := strings.TrimSpace(m[7]); customerRef != "" && customerRef != "NONREF" {
+		tx.TxDesc = customerRef
+	}
+
+	return tx, nil
+}
+
+// mt940EntryDate resolves the MMDD entry date against the value date's year,
+// allowing for entries booked across a year end
+func mt940EntryDate(mmdd string, valueDate time.Time) (time.Time, error) {
+	month, _ := strconv.Atoi(mmdd[:2])
+	day, _ := strconv.Atoi(mmdd[2:])
+	if month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("invalid :61: entry date: %s", mmdd)
+	}
+
+	year := valueDate.Year()
+	switch {
+	case valueDate.Month() == time.December && month == 1:
+		year++
+	case valueDate.Month() == time.January && month == 12:
+		year--
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC), nil
+}
+
+// applyMT940Narrative fills the description and counterparty from :86:.
+// Structured narratives (?20-?29 purpose, ?32/?33 name) are split up, free
+// text becomes the description as is.
+func applyMT940Narrative(tx *domain.Transaction, narrative string) {
+	if !mt940Subfield.MatchString(narrative) {
+		if text := strings.Join(strings.Fields(narrative), " "); text != "" {
+			tx.TxDesc = text
+		}
+		return
+	}
+
+	// structured lines are wrapped at a fixed width, mid-word if need be
+	narrative = strings.ReplaceAll(narrative, "\n", "")
+
+	indexes := mt940Subfield.FindAllStringSubmatchIndex(narrative, -1)
+	var purpose, name []string
+	for i, idx := range indexes {
+		end := len(narrative)
+		if i+1 < len(indexes) {
+			end = indexes[i+1][0]
+		}
+		code, _ := strconv.Atoi(narrative[idx[2]:idx[3]])
+		text := narrative[idx[1]:end]
+		switch {
+		case code >= 20 && code <= 29, code >= 60 && code <= 63:
+			purpose = append(purpose, text)
+		case code == 32 || code == 33:
+			name = append(name, text)
+		}
+	}
+
+	tx.Merchant = strings.TrimSpace(strings.Join(name, ""))
+	if text := strings.TrimSpace(strings.Join(purpose, "")); text != "" {
+		tx.TxDesc = text
+	} else if tx.Merchant != "" {
+		tx.TxDesc = tx.Merchant
+	}
+}
+
+func parseMT940Balance(value string) (float64, time.Time, string, error) {
+	m := mt940Balance.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, time.Time{}, "", fmt.Errorf("invalid balance: %s", value)
+	}
+
+	date, err := time.Parse("060102", m[2])
+	if err != nil {
+		return 0, time.Time{}, "", fmt.Errorf("invalid balance date: %s", m[2])
+	}
+	amount, err := parseAmount(m[4], true)
+	if err != nil {
+		return 0, time.Time{}, "", err
+	}
+	if m[1] == "D" {
+		amount = -amount
+	}
+	return amount, date, m[3], nil
+}
@@ -0,0 +1,134 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestParseMT940Statements(t *testing.T) {
+	transactions, statements, err := ParseMT940(filepath.Join("testdata", "statement.sta"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		account string
+		opening float64
+		closing float64
+		start   string
+		end     string
+	}{
+		{"50010517/5319125", 1000, 2485.50, "2024-12-31", "2025-01-31"},
+		{"50010517/5319999", 0, 5, "2025-01-31", "2025-02-01"},
+	}
+	if len(statements) != len(tests) {
+		t.Fatalf("got %d statements, want %d", len(statements), len(tests))
+	}
+	for i, tt := range tests {
+		s := statements[i]
+		if s.AccountNumber != tt.account || s.AccountType != "chequing" || s.Currency != "EUR" {
+			t.Errorf("%d: got account %s %s %s", i, s.AccountNumber, s.AccountType, s.Currency)
+		}
+		if *s.OpeningBalance != tt.opening || *s.ClosingBalance != tt.closing {
+			t.Errorf("%d: got balances %.2f, %.2f, want %.2f, %.2f", i, *s.OpeningBalance, *s.ClosingBalance, tt.opening, tt.closing)
+		}
+		if s.PeriodStart.Format(time.DateOnly) != tt.start || s.PeriodEnd.Format(time.DateOnly) != tt.end {
+			t.Errorf("%d: got period %s to %s, want %s to %s", i, s.PeriodStart, s.PeriodEnd, tt.start, tt.end)
+		}
+	}
+
+	// each :86: describes the :61: above it, continuation lines included
+	checkTransactions(t, transactions, []wantTx{
+		{"2025-01-15", 4.50, domain.Out, "Kartenzahlung Filiale 12", "50010517/5319125", "chequing"},
+		{"2025-01-20", 1500, domain.In, "SALARY JANUARY ACME GMBH", "50010517/5319125", "chequing"},
+		{"2024-12-31", 20, domain.Out, "ACCOUNT FEE", "50010517/5319125", "chequing"},
+		{"2025-01-25", 10, domain.In, "REFUND", "50010517/5319125", "chequing"},
+		{"2025-02-01", 5, domain.In, "INTEREST", "50010517/5319999", "chequing"},
+	})
+	if transactions[0].Merchant != "BAECKEREI MUELLER" {
+		t.Errorf("got merchant %q, want the ?32 name joined across lines", transactions[0].Merchant)
+	}
+}
+
+func TestParseMT940AccountType(t *testing.T) {
+	transactions, statements, err := ParseMT940(filepath.Join("testdata", "statement.sta"), "savings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transactions[0].StatementAccountType != "savings" || statements[0].AccountType != "savings" {
+		t.Fatalf("got account type %s on transactions, %s on statements, want savings", transactions[0].StatementAccountType, statements[0].AccountType)
+	}
+}
+
+func TestParseMT940Line(t *testing.T) {
+	tests := []struct {
+		name        string
+		line        string
+		direction   domain.Direction
+		amount      float64
+		date        string
+		postingDate string
+		reference   string
+		desc        string
+	}{
+		{"debit with entry date", "2501150116D4,50NMSCNONREF//BNK001", domain.Out, 4.50, "2025-01-15", "2025-01-16", "BNK001", "NMSC"},
+		{"credit without entry date", "250120C1500,00NTRFPAYROLL JAN", domain.In, 1500, "2025-01-20", "2025-01-20", "", "PAYROLL JAN"},
+		{"entry date in the next year", "2412310102D20,00NCHGNONREF//BNK003\nACCOUNT FEE", domain.Out, 20, "2024-12-31", "2025-01-02", "BNK003", "ACCOUNT FEE"},
+		{"entry date in the previous year", "2501021231C7,00NINTNONREF", domain.In, 7, "2025-01-02", "2024-12-31", "", "NINT"},
+		{"reversed debit", "250125RD10,00NMSCNONREF//BNK004", domain.In, 10, "2025-01-25", "2025-01-25", "BNK004", "NMSC"},
+		{"reversed credit", "250125RC10,00NMSCNONREF", domain.Out, 10, "2025-01-25", "2025-01-25", "", "NMSC"},
+		{"funds code", "250125DR10,00NMSCINVOICE 42", domain.Out, 10, "2025-01-25", "2025-01-25", "", "INVOICE 42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx, err := parseMT940Line(tt.line, "EUR")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tx.TxDirection != tt.direction || tx.TxAmount != tt.amount {
+				t.Errorf("got %.2f %s, want %.2f %s", tx.TxAmount, tx.TxDirection, tt.amount, tt.direction)
+			}
+			if tx.TxDate.Format(time.DateOnly) != tt.date || tx.PostingDate.Format(time.DateOnly) != tt.postingDate {
+				t.Errorf("got dates %s / %s, want %s / %s", tx.TxDate.Format(time.DateOnly), tx.PostingDate.Format(time.DateOnly), tt.date, tt.postingDate)
+			}
+			if tx.ExternalID != tt.reference || tx.TxDesc != tt.desc {
+				t.Errorf("got %q %q, want %q %q", tx.ExternalID, tx.TxDesc, tt.reference, tt.desc)
+			}
+		})
+	}
+
+	for _, line := range []string{"25011XD4,50NMSC", "2501151332D4,50NMSC", "250115X4,50NMSC"} {
+		if _, err := parseMT940Line(line, "EUR"); err == nil {
+			t.Errorf("%q: parsed an invalid line", line)
+		}
+	}
+}
+
+func TestApplyMT940Narrative(t *testing.T) {
+	tests := []struct {
+		name      string
+		narrative string
+		desc      string
+		merchant  string
+	}{
+		{"free text", "SALARY JANUARY\nACME  GMBH", "SALARY JANUARY ACME GMBH", ""},
+		{"structured, wrapped mid-word", "?20Kartenzahlung ?21Filiale 12?32BAECKEREI MUEL\nLER", "Kartenzahlung Filiale 12", "BAECKEREI MUELLER"},
+		{"name over ?32 and ?33", "?00GUTSCHRIFT?32ACME GMBH NIEDERLAS?33SUNG BERLIN", "ACME GMBH NIEDERLASSUNG BERLIN", "ACME GMBH NIEDERLASSUNG BERLIN"},
+		{"purpose continued in ?60", "?20RECHNUNG 2025-?21001?60UND 002", "RECHNUNG 2025-001UND 002", ""},
+		{"empty", "  \n", "NMSC", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := &domain.Transaction{TxDesc: "NMSC"}
+			applyMT940Narrative(tx, tt.narrative)
+			if tx.TxDesc != tt.desc || tx.Merchant != tt.merchant {
+				t.Errorf("got %q %q, want %q %q", tx.TxDesc, tx.Merchant, tt.desc, tt.merchant)
+			}
+		})
+	}
+}
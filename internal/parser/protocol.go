@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// protocolVersion is the version of the line-delimited stream main.py writes
+// with --format ndjson. Each line is one JSON record tagged by "type":
+//
+//	header       {"version": 1}, always first
+//	transaction  one parsed statement line (PythonTransaction)
+//	file         status of one PDF after its transactions (FileResult)
+//	summary      totals, always last
+const protocolVersion = 1
+
+const (
+	recordHeader      = "header"
+	recordTransaction = "transaction"
+	recordFile        = "file"
+	recordSummary     = "summary"
+
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+type record struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+}
+
+// streamResult is a ParseResult plus whether the summary record arrived, so a
+// process that died halfway is not mistaken for a short statement
+type streamResult struct {
+	*ParseResult
+	complete bool
+}
+
+func decodeStream(r io.Reader) (streamResult, []*domain.Transaction, error) {
+	result := streamResult{ParseResult: &ParseResult{}}
+	var transactions []*domain.Transaction
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	sawHeader := false
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return result, nil, fmt.Errorf("parser output line %d: %w", lineNum, err)
+		}
+		if !sawHeader && rec.Type != recordHeader {
+			return result, nil, fmt.Errorf("parser output line %d: expected header, got %q", lineNum, rec.Type)
+		}
+
+		switch rec.Type {
+		case recordHeader:
+			if rec.Version != protocolVersion {
+				return result, nil, fmt.Errorf("parser speaks protocol version %d, expected %d", rec.Version, protocolVersion)
+			}
+			sawHeader = true
+
+		case recordTransaction:
+			var pt PythonTransaction
+			if err := json.Unmarshal(line, &pt); err != nil {
+				return result, nil, fmt.Errorf("parser output line %d: %w", lineNum, err)
+			}
+			tx, err := convertTransaction(pt)
+			if err != nil {
+				return result, nil, err
+			}
+			result.Transactions = append(result.Transactions, pt)
+			transactions = append(transactions, tx)
+
+		case recordFile:
+			var fr FileResult
+			if err := json.Unmarshal(line, &fr); err != nil {
+				return result, nil, fmt.Errorf("parser output line %d: %w", lineNum, err)
+			}
+			fr.Processed = fr.Status == statusOK
+			result.FileResults = append(result.FileResults, fr)
+
+		case recordSummary:
+			if err := json.Unmarshal(line, &result.Summary); err != nil {
+				return result, nil, fmt.Errorf("parser output line %d: %w", lineNum, err)
+			}
+			result.complete = true
+
+		default:
+			// newer record types within the same version are ignored
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return result, nil, fmt.Errorf("failed to read parser output: %w", err)
+	}
+	if !result.complete {
+		return result, nil, fmt.Errorf("parser output ended before the summary")
+	}
+
+	return result, transactions, nil
+}
+
+func convertTransaction(pt PythonTransaction) (*domain.Transaction, error) {
+	// Parse date
+	txDate, err := time.Parse(pythonDateLayout, pt.Date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse date %s: %w", pt.Date, err)
+	}
+
+	var postingDate time.Time
+	if pt.PostingDate != "" {
+		postingDate, err = time.Parse(pythonDateLayout, pt.PostingDate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse posting date %s: %w", pt.PostingDate, err)
+		}
+	}
+
+	// Determine direction and make amount positive
+	var direction domain.Direction
+	amount := pt.Amount
+	if amount < 0 {
+		direction = domain.Out
+		amount = -amount
+	} else {
+		direction = domain.In
+	}
+
+	// older parsers don't report the currency, RBC statements default to CAD
+	currency := pt.Currency
+	if currency == "" {
+		currency = domain.DefaultCurrency
+	}
+
+	tx := &domain.Transaction{
+		TxDate:                 txDate,
+		PostingDate:            postingDate,
+		TxAmount:               amount,
+		TxCurrency:             currency,
+		TxDirection:            direction,
+		TxDesc:                 pt.Description,
+		Category:               pt.Category,
+		Source:                 domain.SourcePDF,
+		StatementAccountNumber: pt.AccountNumber,
+		StatementAccountType:   pt.AccountType,
+		StatementAccountName:   pt.AccountName,
+		StatementBank:          "RBC",
+		SourceFilePath:         pt.SourceFile,
+	}
+
+	if pt.Code != nil {
+		tx.ExternalID = *pt.Code
+	}
+
+	return tx, nil
+}
@@ -1,15 +1,23 @@
 package parser
 
 import (
-	"encoding/json"
+	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 
 	"null-statement-parser/internal/domain"
 )
 
+const pythonDateLayout = "2006-01-02T15:04:05"
+
 type PythonTransaction struct {
 	Date          string  `json:"date"`
 	Amount        float64 `json:"amount"`
@@ -21,13 +29,33 @@ type PythonTransaction struct {
 	AccountNumber *string `json:"account_number"`
 	AccountType   string  `json:"account_type"`
 	AccountName   string  `json:"account_name"`
+	Currency      string  `json:"currency"`
 	SourceFile    string  `json:"source_file"`
 }
 
 type FileResult struct {
-	File             string `json:"file"`
-	TransactionCount int    `json:"transaction_count"`
-	Processed        bool   `json:"processed"`
+	File             string   `json:"file"`
+	TransactionCount int      `json:"transaction_count"`
+	Processed        bool     `json:"processed"`
+	AccountNumber    *string  `json:"account_number"`
+	AccountType      string   `json:"account_type"`
+	AccountName      string   `json:"account_name"`
+	Currency         string   `json:"currency"`
+	PeriodStart      *string  `json:"period_start"`
+	PeriodEnd        *string  `json:"period_end"`
+	OpeningBalance   *float64 `json:"opening_balance"`
+	ClosingBalance   *float64 `json:"closing_balance"`
+	AccountValue     *float64 `json:"account_value"`
+	// Status is ok, skipped (no transactions) or error
+	Status string `json:"status,omitempty"`
+	// Error is set when the parser failed on this file
+	Error string `json:"error,omitempty"`
+	// StatementError is set when the transactions parsed but the period and
+	// balances did not
+	StatementError string `json:"statement_error,omitempty"`
+	// Encrypted is set when the file is password protected and none of the
+	// passwords opened it
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 type ParseResult struct {
@@ -36,91 +64,288 @@ type ParseResult struct {
 	Summary      struct {
 		TotalFiles        int `json:"total_files"`
 		ProcessedFiles    int `json:"processed_files"`
+		FailedFiles       int `json:"failed_files"`
 		TotalTransactions int `json:"total_transactions"`
 	} `json:"summary"`
 }
 
+// Statements returns the statement metadata of every processed file, and of
+// investment statements without cash activity since their account value is
+// still worth keeping
+func (r *ParseResult) Statements() []*domain.Statement {
+	var statements []*domain.Statement
+
+	for _, fr := range r.FileResults {
+		if !fr.Processed && (fr.AccountValue == nil || fr.Error != "") {
+			continue
+		}
+
+		statement := &domain.Statement{
+			SourceFilePath: fr.File,
+			AccountType:    fr.AccountType,
+			AccountName:    fr.AccountName,
+			Currency:       fr.Currency,
+			OpeningBalance: fr.OpeningBalance,
+			ClosingBalance: fr.ClosingBalance,
+			AccountValue:   fr.AccountValue,
+		}
+		if fr.AccountNumber != nil {
+			statement.AccountNumber = *fr.AccountNumber
+		}
+		if fr.PeriodStart != nil {
+			statement.PeriodStart, _ = time.Parse(pythonDateLayout, *fr.PeriodStart)
+		}
+		if fr.PeriodEnd != nil {
+			statement.PeriodEnd, _ = time.Parse(pythonDateLayout, *fr.PeriodEnd)
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return statements
+}
+
 type PythonParser struct {
 	pythonPath string
 	scriptPath string
+	// Workers bounds how many parser processes run at once
+	Workers int
+
+	// Passwords lists the passwords to try on an encrypted PDF, with hints
+	// for the prompt
+	Passwords func(file string) (passwords, hints []string, err error)
+	// Prompt asks for a password once the listed ones failed. It is called
+	// from one worker at a time; an empty password gives up on the file.
+	Prompt   func(file string, hints []string) (string, error)
+	promptMu sync.Mutex
 }
 
+// maxPromptAttempts is how often a password is asked for per file
+const maxPromptAttempts = 3
+
 func NewPythonParser() *PythonParser {
 	return &PythonParser{
 		pythonPath: "uv",
 		scriptPath: "rbc-statement-parser/main.py",
+		Workers:    DefaultWorkers(),
 	}
 }
 
+// DefaultWorkers is one parser process per CPU, capped at 4 since each one
+// loads its own interpreter and PyMuPDF
+func DefaultWorkers() int {
+	return min(runtime.NumCPU(), 4)
+}
+
+// ParseStatements parses every PDF under pdfPath, one parser process per file
+// with at most Workers running at once. A file that fails is reported in its
+// FileResult instead of failing the whole run. Results are in file name order
+// regardless of which process finishes first.
 func (p *PythonParser) ParseStatements(pdfPath string, configPath string) (*ParseResult, []*domain.Transaction, error) {
-	// Build command args with JSON format
-	// Only prepend ../ if the path is relative
-	pythonPdfPath := pdfPath
-	if !filepath.IsAbs(pdfPath) {
-		pythonPdfPath = "../" + pdfPath
+	files, err := findPDFs(pdfPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil, fmt.Errorf("no PDF files found in %s", pdfPath)
 	}
 
-	args := []string{"run", "python", "main.py", pythonPdfPath, "--format", "json"}
 	if configPath != "" {
-		pythonConfigPath := configPath
-		if !filepath.IsAbs(configPath) {
-			pythonConfigPath = "../" + configPath
+		if configPath, err = filepath.Abs(configPath); err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve config path: %w", err)
 		}
-		args = append(args, "--config", pythonConfigPath)
 	}
 
-	// Execute Python script with uv from the rbc-statement-parser directory
-	cmd := exec.Command(p.pythonPath, args...)
-	cmd.Dir = "rbc-statement-parser" // Set working directory
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to execute Python parser: %w\nOutput: %s", err, string(output))
+	type fileOutput struct {
+		result       *ParseResult
+		transactions []*domain.Transaction
+		err          error
+	}
+	outputs := make([]fileOutput, len(files))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range max(p.Workers, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				result, transactions, err := p.parseFile(files[i], configPath)
+				outputs[i] = fileOutput{result, transactions, err}
+			}
+		}()
+	}
+	for i := range files {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
+
+	merged := &ParseResult{}
+	var transactions []*domain.Transaction
+	for i, output := range outputs {
+		if output.err != nil {
+			merged.FileResults = append(merged.FileResults, FileResult{File: files[i], Status: statusError, Error: output.err.Error()})
+			continue
+		}
+		merged.Transactions = append(merged.Transactions, output.result.Transactions...)
+		merged.FileResults = append(merged.FileResults, output.result.FileResults...)
+		transactions = append(transactions, output.transactions...)
+	}
+
+	// each process sorts its own file by date, restore the overall order
+	sort.SliceStable(transactions, func(i, j int) bool {
+		return transactions[i].TxDate.Before(transactions[j].TxDate)
+	})
 
-	// Parse JSON output
-	return p.parseJSONOutput(string(output))
+	merged.Summary.TotalFiles = len(files)
+	for _, fr := range merged.FileResults {
+		if fr.Processed {
+			merged.Summary.ProcessedFiles++
+		}
+		if fr.Error != "" {
+			merged.Summary.FailedFiles++
+		}
+	}
+	merged.Summary.TotalTransactions = len(transactions)
+
+	return merged, transactions, nil
 }
 
-func (p *PythonParser) parseJSONOutput(output string) (*ParseResult, []*domain.Transaction, error) {
-	var result ParseResult
+// parseFile parses a single PDF, trying the configured passwords and then
+// the prompt when it is encrypted
+func (p *PythonParser) parseFile(file, configPath string) (*ParseResult, []*domain.Transaction, error) {
+	encrypted, err := isEncryptedPDF(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !encrypted {
+		return p.run(file, configPath, nil)
+	}
 
-	if err := json.Unmarshal([]byte(output), &result); err != nil {
-		return nil, nil, fmt.Errorf("failed to parse JSON output: %w", err)
+	var passwords, hints []string
+	if p.Passwords != nil {
+		if passwords, hints, err = p.Passwords(file); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	var transactions []*domain.Transaction
+	result, transactions, err := p.run(file, configPath, passwords)
+	for attempt := 0; err == nil && isLocked(result) && p.Prompt != nil && attempt < maxPromptAttempts; attempt++ {
+		p.promptMu.Lock()
+		password, promptErr := p.Prompt(file, hints)
+		p.promptMu.Unlock()
+		if promptErr != nil {
+			return nil, nil, promptErr
+		}
+		if password == "" {
+			break
+		}
+		result, transactions, err = p.run(file, configPath, []string{password})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
 
-	for _, pt := range result.Transactions {
-		// Parse date
-		txDate, err := time.Parse("2006-01-02T15:04:05", pt.Date)
-		if err != nil {
-			return nil, nil, fmt.Errorf("failed to parse date %s: %w", pt.Date, err)
+	if isLocked(result) {
+		for i := range result.FileResults {
+			result.FileResults[i].Error = "password protected and no password opened it, add one to pdf_passwords in the config profile"
 		}
+	}
+	return result, transactions, nil
+}
 
-		// Determine direction and make amount positive
-		var direction domain.Direction
-		amount := pt.Amount
-		if amount < 0 {
-			direction = domain.Out
-			amount = -amount
-		} else {
-			direction = domain.In
+// isLocked reports whether the parser could not decrypt the file
+func isLocked(result *ParseResult) bool {
+	for _, fr := range result.FileResults {
+		if fr.Encrypted {
+			return true
 		}
+	}
+	return false
+}
 
-		tx := &domain.Transaction{
-			TxDate:                 txDate,
-			TxAmount:               amount,
-			TxCurrency:             "CAD", // Default to CAD for RBC statements
-			TxDirection:            direction,
-			TxDesc:                 pt.Description,
-			StatementAccountNumber: pt.AccountNumber,
-			StatementAccountType:   pt.AccountType,
-			StatementAccountName:   pt.AccountName,
-			SourceFilePath:         pt.SourceFile,
+// isEncryptedPDF looks for the /Encrypt entry every encrypted PDF has in its
+// trailer, which is never inside a compressed stream
+func isEncryptedPDF(file string) (bool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return bytes.Contains(data, []byte("/Encrypt")), nil
+}
+
+// run executes the parser on a single PDF and decodes its record stream.
+// passwords are handed over in the environment, not on the command line
+// where other users could see them.
+func (p *PythonParser) run(file, configPath string, passwords []string) (*ParseResult, []*domain.Transaction, error) {
+	args := []string{"run", "python", "main.py", file, "--format", "ndjson"}
+	if configPath != "" {
+		args = append(args, "--config", configPath)
+	}
+
+	// Execute Python script with uv from the rbc-statement-parser directory.
+	// stderr is kept apart so stray warnings can't corrupt the stream.
+	var stderr bytes.Buffer
+	cmd := exec.Command(p.pythonPath, args...)
+	cmd.Dir = filepath.Dir(p.scriptPath)
+	cmd.Stderr = &stderr
+	if len(passwords) > 0 {
+		cmd.Env = append(os.Environ(), "PDF_PASSWORDS="+strings.Join(passwords, "\n"))
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to start Python parser: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, nil, fmt.Errorf("failed to start Python parser: %w", err)
+	}
+
+	result, transactions, streamErr := decodeStream(stdout)
+	io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+
+	if waitErr != nil && (streamErr != nil || !result.complete) {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return nil, nil, fmt.Errorf("failed to execute Python parser: %w\nOutput: %s", waitErr, detail)
+		}
+		return nil, nil, fmt.Errorf("failed to execute Python parser: %w", waitErr)
+	}
+	if streamErr != nil {
+		return nil, nil, streamErr
+	}
+	return result.ParseResult, transactions, nil
+}
+
+// findPDFs lists the PDFs at path, which may be a single file or a directory
+// (not searched recursively), sorted by name
+func findPDFs(path string) ([]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		if !strings.EqualFold(filepath.Ext(abs), ".pdf") {
+			return nil, nil
 		}
+		return []string{abs}, nil
+	}
 
-		transactions = append(transactions, tx)
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
 	}
 
-	return &result, transactions, nil
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".pdf") {
+			files = append(files, filepath.Join(abs, entry.Name()))
+		}
+	}
+	return files, nil
 }
@@ -0,0 +1,276 @@
+package parser
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+// qifAccountTypes maps QIF section types onto statement account types.
+// Sections not listed (categories, classes, memorized and investment
+// transactions) are skipped.
+var qifAccountTypes = map[string]string{
+	"bank":  "chequing",
+	"ccard": "credit_card",
+	"cash":  "other",
+	"oth a": "other",
+	"oth l": "other",
+}
+
+type qifSplit struct {
+	category string
+	memo     string
+	amount   string
+}
+
+type qifRecord struct {
+	fields map[byte]string
+	splits []qifSplit
+}
+
+// ParseQIF parses a Quicken Interchange Format file. Transactions are
+// attributed to the account named in the preceding !Account block, or to the
+// file name when there is none. Split lines are kept on one transaction: the
+// category is used when all splits share it, and the breakdown goes into the
+// notes.
+func ParseQIF(path string) ([]*domain.Transaction, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open QIF file: %w", err)
+	}
+	defer file.Close()
+
+	accountName := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	accountType := ""
+	section := ""
+	inAccountBlock := false
+	// an !Account block names the account, otherwise each !Type section
+	// sets the type
+	namedAccount := false
+
+	var transactions []*domain.Transaction
+	record := qifRecord{fields: make(map[byte]string)}
+
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r ")
+		if lineNum == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+		if line == "" {
+			continue
+		}
+
+		if line[0] == '!' {
+			header := strings.ToLower(strings.TrimSpace(line[1:]))
+			switch {
+			case header == "account":
+				inAccountBlock = true
+				namedAccount = true
+			case strings.HasPrefix(header, "type:"):
+				inAccountBlock = false
+				section = strings.TrimPrefix(header, "type:")
+				if t, ok := qifAccountTypes[section]; ok && (!namedAccount || accountType == "") {
+					accountType = t
+				}
+			}
+			// !Option and !Clear lines only toggle Quicken behaviour
+			continue
+		}
+
+		code, value := line[0], strings.TrimSpace(line[1:])
+
+		if inAccountBlock {
+			switch code {
+			case 'N':
+				accountName = value
+				accountType = ""
+			case 'T':
+				accountType = qifAccountTypes[strings.ToLower(value)]
+			case '^':
+				inAccountBlock = false
+			}
+			continue
+		}
+
+		if code != '^' {
+			switch code {
+			case 'S':
+				record.splits = append(record.splits, qifSplit{category: value})
+			case 'E', '$':
+				if len(record.splits) == 0 {
+					record.splits = append(record.splits, qifSplit{})
+				}
+				split := &record.splits[len(record.splits)-1]
+				if code == 'E' {
+					split.memo = value
+				} else {
+					split.amount = value
+				}
+			default:
+				record.fields[code] = value
+			}
+			continue
+		}
+
+		if _, ok := qifAccountTypes[section]; ok && len(record.fields) > 0 {
+			tx, err := qifTransaction(record, accountName, accountType, path)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: skipping QIF record ending on line %d: %v\n", lineNum, err)
+			} else if tx != nil {
+				transactions = append(transactions, tx)
+			}
+		}
+		record = qifRecord{fields: make(map[byte]string)}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read QIF file: %w", err)
+	}
+
+	return transactions, nil
+}
+
+func qifTransaction(record qifRecord, accountName, accountType, sourcePath string) (*domain.Transaction, error) {
+	txDate, err := parseQIFDate(record.fields['D'])
+	if err != nil {
+		return nil, err
+	}
+
+	amountStr := record.fields['T']
+	if amountStr == "" {
+		amountStr = record.fields['U']
+	}
+	amount, err := parseAmount(amountStr, false)
+	if err != nil {
+		return nil, err
+	}
+	if amount == 0 {
+		return nil, nil
+	}
+
+	payee := record.fields['P']
+	memo := record.fields['M']
+	description := payee
+	if description == "" {
+		description = memo
+	}
+	if description == "" {
+		return nil, fmt.Errorf("no payee or memo")
+	}
+
+	var notes []string
+	if memo != "" && memo != description {
+		notes = append(notes, memo)
+	}
+	if number := record.fields['N']; number != "" {
+		notes = append(notes, "check: "+number)
+	}
+
+	category, transfer := qifCategory(record.fields['L'])
+	if transfer != "" {
+		notes = append(notes, "transfer: "+transfer)
+	}
+
+	if len(record.splits) > 0 {
+		category = ""
+		shared := true
+		var parts []string
+		for i, split := range record.splits {
+			splitCategory, splitTransfer := qifCategory(split.category)
+			if splitTransfer != "" {
+				splitCategory = "[" + splitTransfer + "]"
+			}
+			if i == 0 {
+				category = splitCategory
+			} else if splitCategory != category {
+				shared = false
+			}
+
+			part := strings.TrimSpace(splitCategory + " " + split.amount)
+			if split.memo != "" {
+				part += " (" + split.memo + ")"
+			}
+			parts = append(parts, part)
+		}
+		if !shared || strings.HasPrefix(category, "[") {
+			category = ""
+		}
+		notes = append(notes, "split: "+strings.Join(parts, "; "))
+	}
+
+	direction := domain.In
+	if amount < 0 {
+		direction = domain.Out
+		amount = -amount
+	}
+
+	return &domain.Transaction{
+		TxDate:                 txDate,
+		TxAmount:               amount,
+		TxCurrency:             domain.DefaultCurrency,
+		TxDirection:            direction,
+		TxDesc:                 description,
+		Merchant:               payee,
+		UserNotes:              strings.Join(notes, "\n"),
+		Category:               category,
+		Source:                 domain.SourceQIF,
+		StatementAccountNumber: &accountName,
+		StatementAccountType:   accountType,
+		StatementAccountName:   accountName,
+		SourceFilePath:         sourcePath,
+	}, nil
+}
+
+// qifCategory splits an L field into a category, or the account name when it
+// is a transfer written as [Account]. Any /class suffix is dropped.
+func qifCategory(field string) (category, transfer string) {
+	field, _, _ = strings.Cut(field, "/")
+	field = strings.TrimSpace(field)
+	if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+		return "", field[1 : len(field)-1]
+	}
+	return field, ""
+}
+
+// parseQIFDate handles the layouts Quicken and friends write: 01/02/2025,
+// 1/2'25, 01/02/25, 01-02-2025 and space padded variants such as " 1/ 2'25".
+// An apostrophe before a two digit year means 20xx; a slash means 19xx for
+// years from 70 on and 20xx below that.
+func parseQIFDate(s string) (time.Time, error) {
+	cleaned := strings.ReplaceAll(strings.TrimSpace(s), " ", "")
+	apostrophe := strings.Contains(cleaned, "'")
+
+	parts := strings.FieldsFunc(cleaned, func(r rune) bool {
+		return r == '/' || r == '\'' || r == '-' || r == '.'
+	})
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date format: %s", s)
+	}
+
+	month, errM := strconv.Atoi(parts[0])
+	day, errD := strconv.Atoi(parts[1])
+	year, errY := strconv.Atoi(parts[2])
+	if errM != nil || errD != nil || errY != nil || month < 1 || month > 12 || day < 1 || day > 31 {
+		return time.Time{}, fmt.Errorf("invalid date format: %s", s)
+	}
+
+	if len(parts[2]) <= 2 {
+		if apostrophe || year < 70 {
+			year += 2000
+		} else {
+			year += 1900
+		}
+	}
+
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if date.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date: %s", s)
+	}
+	return date, nil
+}
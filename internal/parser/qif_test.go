@@ -0,0 +1,90 @@
+package parser
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+func TestParseQIF(t *testing.T) {
+	transactions, err := ParseQIF(filepath.Join("testdata", "quicken.qif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the bad date and the zero amount are skipped
+	checkTransactions(t, transactions, []wantTx{
+		{"2025-01-15", 4.50, domain.Out, "TIM HORTONS", "Everyday Chequing", "chequing"},
+		{"2025-01-16", 1500, domain.In, "ACME CORP", "Everyday Chequing", "chequing"},
+		{"2025-01-20", 200, domain.Out, "GROCERY STORE", "Everyday Chequing", "chequing"},
+		{"2025-01-25", 300, domain.Out, "Transfer to savings", "Everyday Chequing", "chequing"},
+		{"2025-01-18", 25.40, domain.Out, "UBER EATS", "Visa", "credit_card"},
+	})
+
+	tests := []struct {
+		category string
+		notes    string
+	}{
+		{"Dining", "Coffee"},
+		{"Salary", ""},
+		{"", "split: Groceries -150.00 (Food); Household -50.00"},
+		{"", "check: 101\ntransfer: Savings"},
+		{"", ""},
+	}
+	for i, tt := range tests {
+		if tx := transactions[i]; tx.Category != tt.category || tx.UserNotes != tt.notes {
+			t.Errorf("%d: got category %q, notes %q, want %q, %q", i, tx.Category, tx.UserNotes, tt.category, tt.notes)
+		}
+	}
+}
+
+func TestParseQIFNamesAccountAfterFile(t *testing.T) {
+	transactions, err := ParseQIF(filepath.Join("testdata", "cash.qif"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTransactions(t, transactions, []wantTx{
+		{"1999-12-31", 10, domain.Out, "FARMERS MARKET", "cash", "other"},
+	})
+}
+
+func TestParseQIFDate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"01/02/2025", "2025-01-02"},
+		{"1/2'25", "2025-01-02"},
+		{" 1/ 2'25", "2025-01-02"},
+		{"01-02-2025", "2025-01-02"},
+		// an apostrophe always means 20xx
+		{"12/31'99", "2099-12-31"},
+		{"12/31'05", "2005-12-31"},
+		// a slash means 19xx from 70 on
+		{"12/31/99", "1999-12-31"},
+		{"12/31/70", "1970-12-31"},
+		{"12/31/69", "2069-12-31"},
+		{"01/02/25", "2025-01-02"},
+		{"02/30/2025", ""},
+		{"13/01/2025", ""},
+		{"2025-01-02", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		date, err := parseQIFDate(tt.in)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("%q: got %s, want an error", tt.in, date.Format(time.DateOnly))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.in, err)
+		} else if got := date.Format(time.DateOnly); got != tt.want {
+			t.Errorf("%q: got %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
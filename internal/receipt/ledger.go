@@ -0,0 +1,67 @@
+package receipt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+type LedgerEntry struct {
+	File       string    `json:"file"`
+	ReceiptID  int64     `json:"receipt_id"`
+	UploadedAt time.Time `json:"uploaded_at"`
+}
+
+// Ledger remembers which receipt images were already uploaded, keyed by the
+// SHA-256 of their contents so renamed or moved files are not sent twice
+type Ledger struct {
+	path    string
+	Entries map[string]LedgerEntry `json:"entries"`
+}
+
+func LoadLedger(path string) (*Ledger, error) {
+	ledger := &Ledger{path: path, Entries: make(map[string]LedgerEntry)}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return ledger, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read receipt ledger: %w", err)
+	}
+
+	if err := json.Unmarshal(data, ledger); err != nil {
+		return nil, fmt.Errorf("failed to parse receipt ledger: %w", err)
+	}
+	if ledger.Entries == nil {
+		ledger.Entries = make(map[string]LedgerEntry)
+	}
+
+	return ledger, nil
+}
+
+func (l *Ledger) Lookup(hash string) (LedgerEntry, bool) {
+	entry, ok := l.Entries[hash]
+	return entry, ok
+}
+
+func (l *Ledger) Record(hash, file string, receiptID int64) {
+	l.Entries[hash] = LedgerEntry{
+		File:       file,
+		ReceiptID:  receiptID,
+		UploadedAt: time.Now(),
+	}
+}
+
+func (l *Ledger) Save() error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode receipt ledger: %w", err)
+	}
+	if err := os.WriteFile(l.path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write receipt ledger: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package receipt
+
+import (
+	"sort"
+	"time"
+
+	"null-statement-parser/internal/client"
+	pb "null-statement-parser/internal/gen/null/v1"
+	"null-statement-parser/internal/similarity"
+)
+
+// Weights of the individual signals in a candidate's overall score
+const (
+	amountWeight   = 0.5
+	dateWeight     = 0.3
+	merchantWeight = 0.2
+)
+
+type MatchOptions struct {
+	// WindowDays is how far a transaction date may be from the receipt date
+	WindowDays int
+	// TipAllowance is the fraction by which a transaction may exceed the
+	// receipt total, to account for tips added after the receipt was printed
+	TipAllowance float64
+}
+
+func DefaultMatchOptions() MatchOptions {
+	return MatchOptions{WindowDays: 5, TipAllowance: 0.25}
+}
+
+type Candidate struct {
+	Transaction   *pb.Transaction
+	Score         float64
+	AmountScore   float64
+	DateScore     float64
+	MerchantScore float64
+	DateDiffDays  int
+}
+
+// Date returns the receipt date at midnight UTC, or false if the receipt has
+// none
+func Date(r *pb.Receipt) (time.Time, bool) {
+	d := r.GetReceiptDate()
+	if d == nil || d.Year == 0 {
+		return time.Time{}, false
+	}
+	return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), 0, 0, 0, 0, time.UTC), true
+}
+
+// ScoreCandidates ranks outgoing transactions against a receipt by total, date
+// proximity and merchant similarity. Transactions whose amount or date can't
+// plausibly belong to the receipt are dropped; the rest are returned best
+// first.
+func ScoreCandidates(r *pb.Receipt, transactions []*pb.Transaction, opts MatchOptions) []Candidate {
+	receiptDate, ok := Date(r)
+	if !ok || r.GetTotal() == nil {
+		return nil
+	}
+	totalCents := client.MoneyToCents(r.GetTotal())
+	currency := r.GetTotal().GetCurrencyCode()
+
+	var candidates []Candidate
+	for _, tx := range transactions {
+		if tx.Direction != pb.TransactionDirection_DIRECTION_OUTGOING {
+			continue
+		}
+		if currency != "" && tx.GetTxAmount().GetCurrencyCode() != currency {
+			continue
+		}
+
+		amountScore := scoreAmount(totalCents, client.MoneyToCents(tx.GetTxAmount()), opts.TipAllowance)
+		if amountScore == 0 {
+			continue
+		}
+
+		diff := daysBetween(receiptDate, tx.GetTxDate().AsTime())
+		if diff > opts.WindowDays {
+			continue
+		}
+		dateScore := 1 - float64(diff)/float64(opts.WindowDays+1)
+
+		merchantScore := similarity.Merchant(r.GetMerchant(), transactionMerchant(tx))
+
+		candidates = append(candidates, Candidate{
+			Transaction:   tx,
+			Score:         amountWeight*amountScore + dateWeight*dateScore + merchantWeight*merchantScore,
+			AmountScore:   amountScore,
+			DateScore:     dateScore,
+			MerchantScore: merchantScore,
+			DateDiffDays:  diff,
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Score > candidates[j].Score
+	})
+
+	return candidates
+}
+
+// IsConfident reports whether the best candidate is good enough, and far
+// enough ahead of the runner-up, to link without asking
+func IsConfident(candidates []Candidate, minScore, minMargin float64) bool {
+	if len(candidates) == 0 || candidates[0].Score < minScore {
+		return false
+	}
+	return len(candidates) == 1 || candidates[0].Score-candidates[1].Score >= minMargin
+}
+
+func scoreAmount(totalCents, txCents int64, tipAllowance float64) float64 {
+	diff := txCents - totalCents
+	switch {
+	case diff == 0:
+		return 1
+	case diff < 0:
+		// rounding or a partial refund at the till, only tolerate a few cents
+		if -diff <= 5 {
+			return 0.8
+		}
+		return 0
+	default:
+		maxTip := int64(float64(totalCents) * tipAllowance)
+		if diff > maxTip {
+			return 0
+		}
+		return 0.7 - 0.4*float64(diff)/float64(maxTip)
+	}
+}
+
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.UTC().Date()
+	da := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	db := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+
+	days := int(db.Sub(da).Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
+
+func transactionMerchant(tx *pb.Transaction) string {
+	if tx.GetMerchant() != "" {
+		return tx.GetMerchant()
+	}
+	return tx.GetDescription()
+}
@@ -0,0 +1,65 @@
+package receipt
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"null-statement-parser/internal/client"
+	pb "null-statement-parser/internal/gen/null/v1"
+
+	"github.com/charmbracelet/huh"
+)
+
+const (
+	OptionSkip = "__skip__"
+)
+
+// PromptForLink asks the user which candidate transaction a receipt belongs
+// to. It returns the chosen transaction ID, or 0 if the receipt was skipped.
+func PromptForLink(r *pb.Receipt, candidates []Candidate) (int64, error) {
+	var selectedOption string
+
+	options := make([]huh.Option[string], 0, len(candidates)+1)
+	for _, c := range candidates {
+		tx := c.Transaction
+		label := fmt.Sprintf("%s  %.2f %s  %s  (%s, score %.2f)",
+			tx.GetTxDate().AsTime().Format("2006-01-02"),
+			client.MoneyToFloat(tx.GetTxAmount()),
+			tx.GetTxAmount().GetCurrencyCode(),
+			transactionMerchant(tx),
+			tx.GetAccountName(),
+			c.Score,
+		)
+		options = append(options, huh.NewOption(label, strconv.FormatInt(tx.Id, 10)))
+	}
+	options = append(options, huh.NewOption("Skip this receipt", OptionSkip))
+
+	title := fmt.Sprintf("Receipt %d: %s", r.Id, r.GetMerchant())
+	if d, ok := Date(r); ok {
+		title += " on " + d.Format("2006-01-02")
+	}
+	if total := r.GetTotal(); total != nil {
+		title += fmt.Sprintf(", %.2f %s", client.MoneyToFloat(total), total.CurrencyCode)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title(title).
+				Description("Link to:").
+				Options(options...).
+				Value(&selectedOption),
+		),
+	).WithOutput(os.Stderr)
+
+	if err := form.Run(); err != nil {
+		return 0, fmt.Errorf("prompt failed: %w", err)
+	}
+
+	if selectedOption == OptionSkip {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(selectedOption, 10, 64)
+}
@@ -0,0 +1,200 @@
+// Package reconcile pairs statement lines with provisional rows (e.g. from a
+// CSV export) that were uploaded to ariand before the statement was available
+package reconcile
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/domain"
+	pb "null-statement-parser/internal/gen/null/v1"
+	"null-statement-parser/internal/similarity"
+)
+
+type Options struct {
+	// WindowDays is how far the provisional row's date may be from the
+	// statement line, CSV exports often carry the posting date
+	WindowDays int
+	// AmountTolerance is how far the provisional amount may be off, as a
+	// share of the statement amount: a tip added after the card was
+	// authorised, or a foreign charge converted at another day's rate
+	AmountTolerance float64
+	// MatchUntagged also considers rows without a source tag, such as CSV
+	// rows imported before rows were tagged. Off by default, since such a row
+	// may as well have been entered by hand or be a genuine repeat purchase.
+	MatchUntagged bool
+	// MinUntaggedSimilarity is how alike the descriptions of untagged rows
+	// must be
+	MinUntaggedSimilarity float64
+}
+
+func DefaultOptions() Options {
+	return Options{WindowDays: 3, AmountTolerance: 0.2, MinUntaggedSimilarity: 0.6}
+}
+
+type Match struct {
+	Statement *domain.Transaction
+	Existing  *pb.Transaction
+	Score     float64
+}
+
+// Period is the date range a statement covers for one account
+type Period struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Periods works out the date range to search per resolved account. Statement
+// periods are used where known, otherwise the range of the account's lines.
+func Periods(transactions []*domain.Transaction, statements []*domain.Statement) map[int]Period {
+	statementsByFile := make(map[string]*domain.Statement)
+	for _, s := range statements {
+		statementsByFile[s.SourceFilePath] = s
+	}
+
+	periods := make(map[int]Period)
+	extend := func(accountID int, start, end time.Time) {
+		p, ok := periods[accountID]
+		if !ok || start.Before(p.Start) {
+			p.Start = start
+		}
+		if !ok || end.After(p.End) {
+			p.End = end
+		}
+		periods[accountID] = p
+	}
+
+	for _, tx := range transactions {
+		if tx.IsProvisional() {
+			continue
+		}
+		if s := statementsByFile[tx.SourceFilePath]; s != nil && !s.PeriodStart.IsZero() && !s.PeriodEnd.IsZero() {
+			extend(tx.AccountID, s.PeriodStart, s.PeriodEnd)
+			continue
+		}
+		extend(tx.AccountID, tx.TxDate, tx.TxDate)
+	}
+
+	return periods
+}
+
+// Find pairs statement lines with provisional rows already in ariand. Both
+// sides must be on the same account, currency and direction, with amounts
+// within the tolerance and dates within the window; among those, closer
+// dates, more similar descriptions and closer amounts win. Only rows with a
+// provisional source tag are considered, unless opts.MatchUntagged is set;
+// untagged rows then need a similar description and no statement reference.
+// Each side is used at most once.
+func Find(statementTxs []*domain.Transaction, existing []*pb.Transaction, opts Options) []Match {
+	var pairs []Match
+
+	for _, tx := range statementTxs {
+		if tx.IsProvisional() {
+			continue
+		}
+		txCents := client.MoneyToCents(client.NewMoney(tx.TxAmount, tx.TxCurrency))
+
+		for _, e := range existing {
+			if e.AccountId != int64(tx.AccountID) || e.GetTxAmount().GetCurrencyCode() != tx.TxCurrency || direction(e) != tx.TxDirection {
+				continue
+			}
+			amountScore, ok := amountCloseness(txCents, client.MoneyToCents(e.GetTxAmount()), opts.AmountTolerance)
+			if !ok {
+				continue
+			}
+			descScore := similarity.Merchant(tx.TxDesc, e.GetDescription())
+			if !domain.HasProvisionalTag(e.GetUserNotes()) {
+				if !opts.MatchUntagged || domain.HasReference(e.GetUserNotes()) || descScore < opts.MinUntaggedSimilarity {
+					continue
+				}
+			}
+
+			diff := daysBetween(tx.TxDate, e.GetTxDate().AsTime())
+			if !tx.PostingDate.IsZero() {
+				if posted := daysBetween(tx.PostingDate, e.GetTxDate().AsTime()); posted < diff {
+					diff = posted
+				}
+			}
+			if diff > opts.WindowDays {
+				continue
+			}
+
+			dateScore := 1 - float64(diff)/float64(opts.WindowDays+1)
+			pairs = append(pairs, Match{
+				Statement: tx,
+				Existing:  e,
+				Score:     0.5*dateScore + 0.3*descScore + 0.2*amountScore,
+			})
+		}
+	}
+
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return pairs[i].Score > pairs[j].Score
+	})
+
+	usedStatement := make(map[*domain.Transaction]bool)
+	usedExisting := make(map[int64]bool)
+	var matches []Match
+	for _, p := range pairs {
+		if usedStatement[p.Statement] || usedExisting[p.Existing.Id] {
+			continue
+		}
+		usedStatement[p.Statement] = true
+		usedExisting[p.Existing.Id] = true
+		matches = append(matches, p)
+	}
+
+	return matches
+}
+
+// MergedNotes keeps whatever the user wrote on the provisional row, drops its
+// source tag, and adds the statement line's own notes and reference
+func MergedNotes(m Match) string {
+	existing := domain.StripProvisionalTag(m.Existing.GetUserNotes())
+	merged := *m.Statement
+	merged.UserNotes = existing
+	if m.Statement.UserNotes != "" && m.Statement.UserNotes != existing {
+		if existing == "" {
+			merged.UserNotes = m.Statement.UserNotes
+		} else {
+			merged.UserNotes = existing + "\n" + m.Statement.UserNotes
+		}
+	}
+	return merged.UploadNotes()
+}
+
+// amountCloseness scores how close two amounts in cents are, from 1 when
+// equal down to 0 at the tolerance, and reports whether they are within it
+func amountCloseness(want, got int64, tolerance float64) (float64, bool) {
+	diff := math.Abs(float64(want - got))
+	if diff == 0 {
+		return 1, true
+	}
+	allowed := tolerance * math.Abs(float64(want))
+	if diff > allowed {
+		return 0, false
+	}
+	return 1 - diff/(allowed+1), true
+}
+
+func direction(tx *pb.Transaction) domain.Direction {
+	if tx.Direction == pb.TransactionDirection_DIRECTION_INCOMING {
+		return domain.In
+	}
+	return domain.Out
+}
+
+func daysBetween(a, b time.Time) int {
+	ay, am, ad := a.UTC().Date()
+	by, bm, bd := b.UTC().Date()
+	da := time.Date(ay, am, ad, 0, 0, 0, 0, time.UTC)
+	db := time.Date(by, bm, bd, 0, 0, 0, 0, time.UTC)
+
+	days := int(db.Sub(da).Hours() / 24)
+	if days < 0 {
+		return -days
+	}
+	return days
+}
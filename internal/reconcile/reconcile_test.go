@@ -0,0 +1,103 @@
+package reconcile
+
+import (
+	"testing"
+	"time"
+
+	"null-statement-parser/internal/client"
+	"null-statement-parser/internal/domain"
+	pb "null-statement-parser/internal/gen/null/v1"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func existingRow(id int64, amount float64, day int, description, notes string) *pb.Transaction {
+	return &pb.Transaction{
+		Id:          id,
+		AccountId:   1,
+		TxAmount:    client.NewMoney(amount, "CAD"),
+		TxDate:      timestamppb.New(time.Date(2025, 1, day, 0, 0, 0, 0, time.UTC)),
+		Direction:   pb.TransactionDirection_DIRECTION_OUTGOING,
+		Description: &description,
+		UserNotes:   &notes,
+	}
+}
+
+func TestFind(t *testing.T) {
+	statement := &domain.Transaction{
+		AccountID:   1,
+		TxDate:      time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC),
+		TxAmount:    46.50,
+		TxCurrency:  "CAD",
+		TxDirection: domain.Out,
+		TxDesc:      "THE KEG STEAKHOUSE #12",
+		Source:      domain.SourcePDF,
+	}
+
+	tests := []struct {
+		name     string
+		existing []*pb.Transaction
+		untagged bool
+		want     int64
+	}{
+		{
+			name:     "exact tagged row",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 10, "KEG", "source: csv")},
+			want:     1,
+		},
+		{
+			name:     "tip added after authorisation",
+			existing: []*pb.Transaction{existingRow(1, 40.00, 9, "THE KEG STEAKHOUSE", "source: csv")},
+			want:     1,
+		},
+		{
+			name:     "amount too far off",
+			existing: []*pb.Transaction{existingRow(1, 20.00, 10, "THE KEG STEAKHOUSE", "source: csv")},
+		},
+		{
+			name:     "closer amount wins",
+			existing: []*pb.Transaction{existingRow(1, 40.00, 10, "THE KEG", "source: csv"), existingRow(2, 46.50, 10, "THE KEG", "source: csv")},
+			want:     2,
+		},
+		{
+			// it may have been entered by hand
+			name:     "untagged row by default",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 10, "The Keg Steakhouse", "")},
+		},
+		{
+			name:     "untagged row with a similar description",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 10, "The Keg Steakhouse", "")},
+			untagged: true,
+			want:     1,
+		},
+		{
+			name:     "untagged row with another description",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 10, "SHELL GAS", "")},
+			untagged: true,
+		},
+		{
+			name:     "untagged statement line",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 10, "THE KEG STEAKHOUSE #12", "ref: 74500015010000000000123")},
+			untagged: true,
+		},
+		{
+			name:     "outside the window",
+			existing: []*pb.Transaction{existingRow(1, 46.50, 2, "THE KEG STEAKHOUSE", "source: csv")},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			opts.MatchUntagged = tt.untagged
+			matches := Find([]*domain.Transaction{statement}, tt.existing, opts)
+			var got int64
+			if len(matches) > 0 {
+				got = matches[0].Existing.Id
+			}
+			if got != tt.want {
+				t.Fatalf("matched row %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+// Package review implements the full-screen table shown before transactions
+// are uploaded, where rows can be filtered, sorted, excluded and edited
+package review
+
+import (
+	"fmt"
+	"os"
+
+	"null-statement-parser/internal/domain"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+type mode int
+
+const (
+	browsing mode = iota
+	filtering
+	editing
+	confirming
+)
+
+type field int
+
+const (
+	fieldDescription field = iota
+	fieldMerchant
+	fieldNotes
+	fieldCategory
+)
+
+var fieldNames = [...]string{"description", "merchant", "notes", "category"}
+
+type model struct {
+	rows    []*row
+	visible []*row
+	cursor  int
+	offset  int
+	width   int
+	height  int
+
+	mode      mode
+	input     textinput.Model
+	editField field
+	filter    string
+	sortCol   column
+	sortDesc  bool
+
+	confirmed bool
+}
+
+// Run shows the review table for transactions. warnings holds extra
+// per-transaction warnings (e.g. from reconciliation) that are highlighted
+// alongside the detected duplicates. It returns the transactions left
+// included and whether the user confirmed the upload; edits are applied to
+// the transactions in place.
+func Run(transactions []*domain.Transaction, warnings map[*domain.Transaction][]string) ([]*domain.Transaction, bool, error) {
+	m := &model{input: textinput.New()}
+	for i, tx := range transactions {
+		m.rows = append(m.rows, &row{
+			index:    i,
+			tx:       tx,
+			included: true,
+			external: warnings[tx],
+		})
+	}
+	markDuplicates(m.rows)
+	m.refresh()
+
+	program := tea.NewProgram(m, tea.WithAltScreen(), tea.WithOutput(os.Stderr))
+	if _, err := program.Run(); err != nil {
+		return nil, false, fmt.Errorf("review failed: %w", err)
+	}
+
+	if !m.confirmed {
+		return nil, false, nil
+	}
+	return m.selected(), true, nil
+}
+
+func (m *model) selected() []*domain.Transaction {
+	var selected []*domain.Transaction
+	for _, r := range m.rows {
+		if r.included {
+			selected = append(selected, r.tx)
+		}
+	}
+	return selected
+}
+
+// refresh reapplies the filter and sort order, keeping the cursor in range
+func (m *model) refresh() {
+	m.visible = m.visible[:0]
+	for _, r := range m.rows {
+		if r.matches(m.filter) {
+			m.visible = append(m.visible, r)
+		}
+	}
+	sortRows(m.visible, m.sortCol, m.sortDesc)
+
+	if m.cursor >= len(m.visible) {
+		m.cursor = len(m.visible) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+	m.scroll()
+}
+
+func (m *model) pageSize() int {
+	// header, column titles, status line and help line
+	size := m.height - 5
+	if size < 1 {
+		return 20
+	}
+	return size
+}
+
+func (m *model) scroll() {
+	page := m.pageSize()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+page {
+		m.offset = m.cursor - page + 1
+	}
+}
+
+func (m *model) current() *row {
+	if len(m.visible) == 0 {
+		return nil
+	}
+	return m.visible[m.cursor]
+}
+
+func (m *model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.scroll()
+		return m, nil
+	case tea.KeyMsg:
+		switch m.mode {
+		case filtering, editing:
+			return m.updateInput(msg)
+		case confirming:
+			return m.updateConfirm(msg)
+		default:
+			return m.updateBrowse(msg)
+		}
+	}
+	return m, nil
+}
+
+func (m *model) updateBrowse(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "up", "k":
+		m.cursor--
+	case "down", "j":
+		m.cursor++
+	case "pgup", "ctrl+u":
+		m.cursor -= m.pageSize()
+	case "pgdown", "ctrl+d":
+		m.cursor += m.pageSize()
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.visible) - 1
+	case " ", "x":
+		if r := m.current(); r != nil {
+			r.included = !r.included
+		}
+	case "a":
+		// toggle every visible row, including them all unless they already are
+		all := true
+		for _, r := range m.visible {
+			all = all && r.included
+		}
+		for _, r := range m.visible {
+			r.included = !all
+		}
+	case "w":
+		// jump to the next row with a warning
+		for i := 1; i <= len(m.visible); i++ {
+			next := (m.cursor + i) % len(m.visible)
+			if len(m.visible[next].warnings) > 0 {
+				m.cursor = next
+				break
+			}
+		}
+	case "s":
+		m.sortCol = (m.sortCol + 1) % columnCount
+	case "S":
+		m.sortDesc = !m.sortDesc
+	case "/":
+		m.mode = filtering
+		m.input.Prompt = "filter: "
+		m.input.SetValue(m.filter)
+		return m, m.input.Focus()
+	case "e", "m", "n", "c":
+		if r := m.current(); r != nil {
+			m.editField = map[string]field{"e": fieldDescription, "m": fieldMerchant, "n": fieldNotes, "c": fieldCategory}[msg.String()]
+			m.mode = editing
+			m.input.Prompt = fieldNames[m.editField] + ": "
+			m.input.SetValue(m.fieldValue(r))
+			m.input.CursorEnd()
+			return m, m.input.Focus()
+		}
+	case "enter":
+		m.mode = confirming
+	}
+
+	m.refresh()
+	return m, nil
+}
+
+func (m *model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		if m.mode == filtering {
+			m.filter = ""
+		}
+		m.mode = browsing
+		m.input.Blur()
+		m.refresh()
+		return m, nil
+	case "enter":
+		if m.mode == editing {
+			if r := m.current(); r != nil {
+				m.setFieldValue(r, m.input.Value())
+				markDuplicates(m.rows)
+			}
+		}
+		m.mode = browsing
+		m.input.Blur()
+		m.refresh()
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	if m.mode == filtering {
+		m.filter = m.input.Value()
+		m.refresh()
+	}
+	return m, cmd
+}
+
+func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "y", "Y":
+		m.confirmed = true
+		return m, tea.Quit
+	case "ctrl+c":
+		return m, tea.Quit
+	default:
+		m.mode = browsing
+	}
+	return m, nil
+}
+
+func (m *model) fieldValue(r *row) string {
+	switch m.editField {
+	case fieldMerchant:
+		return r.tx.Merchant
+	case fieldNotes:
+		return r.tx.UserNotes
+	case fieldCategory:
+		return r.tx.Category
+	default:
+		return r.tx.TxDesc
+	}
+}
+
+func (m *model) setFieldValue(r *row, value string) {
+	switch m.editField {
+	case fieldMerchant:
+		r.tx.Merchant = value
+	case fieldNotes:
+		r.tx.UserNotes = value
+	case fieldCategory:
+		r.tx.Category = value
+	default:
+		r.tx.TxDesc = value
+	}
+}
@@ -0,0 +1,122 @@
+package review
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/domain"
+)
+
+type column int
+
+const (
+	colDate column = iota
+	colAccount
+	colAmount
+	colDescription
+	colMerchant
+	colCategory
+	columnCount
+)
+
+var columnNames = [...]string{"date", "account", "amount", "description", "merchant", "category"}
+
+type row struct {
+	index    int
+	tx       *domain.Transaction
+	included bool
+	warnings []string
+	// external warnings passed in by the caller, kept apart so duplicate
+	// detection can be recomputed after edits
+	external []string
+}
+
+func (r *row) account() string {
+	if r.tx.StatementAccountNumber != nil && *r.tx.StatementAccountNumber != "" {
+		return *r.tx.StatementAccountNumber
+	}
+	return "Unknown"
+}
+
+func (r *row) signedAmount() float64 {
+	if r.tx.TxDirection == domain.Out {
+		return -r.tx.TxAmount
+	}
+	return r.tx.TxAmount
+}
+
+func (r *row) amount() string {
+	return fmt.Sprintf("%.2f %s", r.signedAmount(), r.tx.TxCurrency)
+}
+
+func (r *row) value(col column) string {
+	switch col {
+	case colDate:
+		return r.tx.TxDate.Format(time.DateOnly)
+	case colAccount:
+		return r.account()
+	case colAmount:
+		return r.amount()
+	case colDescription:
+		return r.tx.TxDesc
+	case colMerchant:
+		return r.tx.Merchant
+	case colCategory:
+		return r.tx.Category
+	}
+	return ""
+}
+
+func (r *row) matches(filter string) bool {
+	if filter == "" {
+		return true
+	}
+	filter = strings.ToLower(filter)
+	for col := column(0); col < columnCount; col++ {
+		if strings.Contains(strings.ToLower(r.value(col)), filter) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(r.tx.UserNotes), filter)
+}
+
+// markDuplicates flags every row whose dedup key (bank reference, or account,
+// date, amount and description) matches an earlier row
+func markDuplicates(rows []*row) {
+	first := make(map[string]int)
+	for _, r := range rows {
+		r.warnings = append([]string(nil), r.external...)
+
+		key := r.tx.DedupKey()
+		if idx, ok := first[key]; ok {
+			r.warnings = append(r.warnings, fmt.Sprintf("possible duplicate of row %d", idx+1))
+			continue
+		}
+		first[key] = r.index
+	}
+}
+
+func sortRows(rows []*row, col column, desc bool) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		var less, equal bool
+		switch col {
+		case colDate:
+			less, equal = a.tx.TxDate.Before(b.tx.TxDate), a.tx.TxDate.Equal(b.tx.TxDate)
+		case colAmount:
+			less, equal = a.signedAmount() < b.signedAmount(), a.signedAmount() == b.signedAmount()
+		default:
+			av, bv := strings.ToLower(a.value(col)), strings.ToLower(b.value(col))
+			less, equal = av < bv, av == bv
+		}
+		if equal {
+			return a.index < b.index
+		}
+		if desc {
+			return !less
+		}
+		return less
+	})
+}
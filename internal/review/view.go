@@ -0,0 +1,172 @@
+package review
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	headerStyle   = lipgloss.NewStyle().Bold(true)
+	titleStyle    = lipgloss.NewStyle().Bold(true).Underline(true)
+	cursorStyle   = lipgloss.NewStyle().Reverse(true)
+	excludedStyle = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+	warningStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	helpStyle     = lipgloss.NewStyle().Faint(true)
+)
+
+// fixed column widths, the description takes whatever is left
+const (
+	widthMark     = 3
+	widthDate     = 10
+	widthAccount  = 16
+	widthAmount   = 14
+	widthMerchant = 20
+	widthCategory = 16
+	widthWarn     = 2
+)
+
+func (m *model) View() string {
+	var b strings.Builder
+
+	included, warned := 0, 0
+	for _, r := range m.rows {
+		if r.included {
+			included++
+		}
+		if len(r.warnings) > 0 {
+			warned++
+		}
+	}
+
+	sortDir := "asc"
+	if m.sortDesc {
+		sortDir = "desc"
+	}
+	header := fmt.Sprintf("review: %d/%d selected, %d shown, %d with warnings, sort: %s %s",
+		included, len(m.rows), len(m.visible), warned, columnNames[m.sortCol], sortDir)
+	if m.filter != "" {
+		header += fmt.Sprintf(", filter: %q", m.filter)
+	}
+	b.WriteString(headerStyle.Render(header))
+	b.WriteString("\n")
+
+	descWidth := m.descriptionWidth()
+	b.WriteString(titleStyle.Render(m.formatLine("", "", "date", "account", "amount", "description", "merchant", "category", descWidth)))
+	b.WriteString("\n")
+
+	end := m.offset + m.pageSize()
+	if end > len(m.visible) {
+		end = len(m.visible)
+	}
+	for i := m.offset; i < end; i++ {
+		r := m.visible[i]
+
+		mark := "[x]"
+		if !r.included {
+			mark = "[ ]"
+		}
+		warn := ""
+		if len(r.warnings) > 0 {
+			warn = "!"
+		}
+
+		line := m.formatLine(mark, warn, r.value(colDate), r.account(), r.amount(),
+			r.tx.TxDesc, r.tx.Merchant, r.tx.Category, descWidth)
+
+		switch {
+		case i == m.cursor:
+			line = cursorStyle.Render(line)
+		case !r.included:
+			line = excludedStyle.Render(line)
+		case len(r.warnings) > 0:
+			line = warningStyle.Render(line)
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	for i := end - m.offset; i < m.pageSize() && m.height > 0; i++ {
+		b.WriteString("\n")
+	}
+
+	b.WriteString(m.statusLine())
+	b.WriteString("\n")
+	b.WriteString(m.helpLine())
+
+	return b.String()
+}
+
+func (m *model) statusLine() string {
+	switch m.mode {
+	case filtering, editing:
+		return m.input.View()
+	case confirming:
+		return headerStyle.Render(fmt.Sprintf("upload %d transactions? (y/N)", len(m.selected())))
+	}
+
+	r := m.current()
+	if r == nil {
+		return ""
+	}
+	if len(r.warnings) > 0 {
+		return warningStyle.Render("! " + strings.Join(r.warnings, "; "))
+	}
+	if r.tx.UserNotes != "" {
+		return "notes: " + r.tx.UserNotes
+	}
+	return ""
+}
+
+func (m *model) helpLine() string {
+	switch m.mode {
+	case filtering:
+		return helpStyle.Render("enter: apply  esc: clear")
+	case editing:
+		return helpStyle.Render("enter: save  esc: cancel")
+	case confirming:
+		return helpStyle.Render("y: upload  any other key: back")
+	}
+	return helpStyle.Render("space: toggle  a: toggle shown  /: filter  s/S: sort  e/m/n/c: edit desc/merchant/notes/category  w: next warning  enter: upload  q: abort")
+}
+
+func (m *model) descriptionWidth() int {
+	fixed := widthMark + widthWarn + widthDate + widthAccount + widthAmount + widthMerchant + widthCategory + 7
+	width := m.width - fixed
+	if width < 20 {
+		return 20
+	}
+	return width
+}
+
+func (m *model) formatLine(mark, warn, date, account, amount, desc, merchant, category string, descWidth int) string {
+	return strings.Join([]string{
+		fit(mark, widthMark),
+		fit(warn, widthWarn-1),
+		fit(date, widthDate),
+		fit(account, widthAccount),
+		fitRight(amount, widthAmount),
+		fit(desc, descWidth),
+		fit(merchant, widthMerchant),
+		fit(category, widthCategory),
+	}, " ")
+}
+
+func fit(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) > width {
+		if width <= 1 {
+			return string(runes[:width])
+		}
+		return string(runes[:width-1]) + "…"
+	}
+	return s + strings.Repeat(" ", width-len(runes))
+}
+
+func fitRight(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) >= width {
+		return fit(s, width)
+	}
+	return strings.Repeat(" ", width-len(runes)) + s
+}
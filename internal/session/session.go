@@ -0,0 +1,158 @@
+// Package session records what each import changed in ariand, so a bad
+// import can be reversed later
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"null-statement-parser/internal/state"
+)
+
+type Alias struct {
+	AccountID int64  `json:"account_id"`
+	Alias     string `json:"alias"`
+}
+
+type Session struct {
+	ID                string    `json:"id"`
+	StartedAt         time.Time `json:"started_at"`
+	Files             []string  `json:"files"`
+	TransactionIDs    []int64   `json:"transaction_ids"`
+	ReconciledIDs     []int64   `json:"reconciled_ids,omitempty"`
+	CreatedAccountIDs []int64   `json:"created_account_ids,omitempty"`
+	Aliases           []Alias   `json:"aliases,omitempty"`
+	UndoneAt          time.Time `json:"undone_at,omitzero"`
+}
+
+func New(files []string) *Session {
+	suffix := make([]byte, 3)
+	rand.Read(suffix)
+
+	now := time.Now()
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		StartedAt: now,
+		Files:     files,
+	}
+}
+
+func (s *Session) AddTransactions(ids ...int64) {
+	s.TransactionIDs = append(s.TransactionIDs, ids...)
+}
+
+func (s *Session) AddReconciled(ids ...int64) {
+	s.ReconciledIDs = append(s.ReconciledIDs, ids...)
+}
+
+func (s *Session) AddCreatedAccount(id int64) {
+	s.CreatedAccountIDs = append(s.CreatedAccountIDs, id)
+}
+
+func (s *Session) AddAlias(accountID int64, alias string) {
+	s.Aliases = append(s.Aliases, Alias{AccountID: accountID, Alias: alias})
+}
+
+// IsEmpty reports whether the session changed nothing worth undoing
+func (s *Session) IsEmpty() bool {
+	return len(s.TransactionIDs) == 0 && len(s.CreatedAccountIDs) == 0 && len(s.Aliases) == 0
+}
+
+func dir() (string, error) {
+	path, err := state.Path("sessions")
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return path, nil
+}
+
+// Save writes the session, it is called after every batch so a crash
+// mid-import still leaves an undoable record
+func (s *Session) Save() error {
+	d, err := dir()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(d, s.ID+".json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	return nil
+}
+
+// Load reads a session by ID. A unique prefix of the ID is accepted.
+func Load(id string) (*Session, error) {
+	sessions, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Session
+	for _, s := range sessions {
+		if s.ID == id {
+			return s, nil
+		}
+		if strings.HasPrefix(s.ID, id) {
+			if found != nil {
+				return nil, fmt.Errorf("session id %q is ambiguous", id)
+			}
+			found = s
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("session %q not found", id)
+	}
+	return found, nil
+}
+
+// List returns all recorded sessions, newest first
+func List() ([]*Session, error) {
+	d, err := dir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(d)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(d, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read session: %w", err)
+		}
+		var s Session
+		if err := json.Unmarshal(data, &s); err != nil {
+			return nil, fmt.Errorf("failed to parse session %s: %w", entry.Name(), err)
+		}
+		sessions = append(sessions, &s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].StartedAt.After(sessions[j].StartedAt)
+	})
+	return sessions, nil
+}
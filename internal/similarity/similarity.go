@@ -0,0 +1,60 @@
+// Package similarity scores how alike two free-text transaction labels are
+package similarity
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Merchant compares two merchant or description strings as written on a
+// receipt, a statement or a CSV export, returning a value between 0 and 1.
+// Store numbers, punctuation and case are ignored.
+func Merchant(a, b string) float64 {
+	ta, tb := tokens(a), tokens(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	joinedA, joinedB := strings.Join(ta, ""), strings.Join(tb, "")
+	if strings.Contains(joinedA, joinedB) || strings.Contains(joinedB, joinedA) {
+		return 1
+	}
+
+	return diceCoefficient(joinedA, joinedB)
+}
+
+func tokens(s string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var tokens []string
+	for _, f := range fields {
+		if strings.IndexFunc(f, unicode.IsLetter) == -1 {
+			continue
+		}
+		tokens = append(tokens, f)
+	}
+	return tokens
+}
+
+func diceCoefficient(a, b string) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 0
+	}
+
+	bigrams := make(map[string]int)
+	for i := 0; i < len(a)-1; i++ {
+		bigrams[a[i:i+2]]++
+	}
+
+	overlap := 0
+	for i := 0; i < len(b)-1; i++ {
+		if bigrams[b[i:i+2]] > 0 {
+			bigrams[b[i:i+2]]--
+			overlap++
+		}
+	}
+
+	return 2 * float64(overlap) / float64(len(a)-1+len(b)-1)
+}
@@ -0,0 +1,37 @@
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const appName = "null-statement-parser"
+
+// Dir returns the directory used for local bookkeeping files, creating it if
+// needed. It honours $XDG_STATE_HOME and falls back to ~/.local/state.
+func Dir() (string, error) {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve home directory: %w", err)
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+
+	dir := filepath.Join(base, appName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("failed to create state directory: %w", err)
+	}
+	return dir, nil
+}
+
+// Path joins name onto the state directory
+func Path(name ...string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{dir}, name...)...), nil
+}
@@ -49,6 +49,41 @@ def extract_start_date(pdf: str) -> datetime | None:
   return None
 
 
+def extract_end_date(pdf: str) -> datetime | None:
+  regex = rf"from ({PAT_DATE_LONG}) to ({PAT_DATE_LONG})"
+
+  if match := re.search(regex, pdf, re.IGNORECASE):
+    end_month = match[6]
+    end_day = match[7]
+    end_year = match[8]
+
+    if end_year:
+      return datetime.strptime(f"{end_month} {end_day} {end_year}", "%B %d %Y")
+
+  return None
+
+
+def extract_balance(pdf: str, kind: str) -> Optional[float]:
+  regex = rf"{kind} balance(?: on)?\s+(?:{PAT_DATE_LONG})?\s*({PAT_AMOUNT})"
+
+  if match := re.search(regex, pdf, re.IGNORECASE):
+    return parse_float(match[4])
+
+  return None
+
+
+def parse_chequing_statement(pdf_path: str) -> dict:
+  """Extract statement period and opening/closing balances"""
+  pdf = read_pdf(pdf_path)
+
+  return {
+    "period_start": extract_start_date(pdf),
+    "period_end": extract_end_date(pdf),
+    "opening_balance": extract_balance(pdf, "opening"),
+    "closing_balance": extract_balance(pdf, "closing"),
+  }
+
+
 def parse_date(string: str) -> datetime:
   return datetime.strptime(string, "%d %b %Y")
 
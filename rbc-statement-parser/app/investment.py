@@ -0,0 +1,185 @@
+import re
+from datetime import datetime
+from typing import Dict, List, Optional
+
+from .entities import Transaction
+from .utils import match_category, parse_float, read_pdf, should_exclude
+
+PAT_MONTH_SHORT = r"jan|feb|mar|apr|may|jun|jul|aug|sep|oct|nov|dec"
+PAT_MONTH_LONG = r"january|february|march|april|may|june|july|august|september|october|november|december"
+PAT_DAY = r"\d{1,2}"
+PAT_YEAR = r"\d{4}"
+PAT_DATE_SHORT = rf"(?:{PAT_MONTH_SHORT})\w* {PAT_DAY}"
+PAT_DATE_LONG = rf"((?:{PAT_MONTH_LONG})) ({PAT_DAY})(?:, )?({PAT_YEAR})?"
+PAT_AMOUNT = r"\(?-?\$?[\d,]+\.\d{2}\)?"
+PAT_PLAN = r"RRSP|TFSA|RRIF|RESP|FHSA|LIRA|Non-Registered"
+
+# Activity types that move cash in or out of the account, with the direction
+# they move it. Buys, sells and security transfers only change what the cash
+# is invested in, so they are skipped.
+CASH_ACTIVITIES = {
+  "contribution": 1,
+  "deposit": 1,
+  "dividend": 1,
+  "distribution": 1,
+  "interest": 1,
+  "withdrawal": -1,
+  "fee": -1,
+  "withholding tax": -1,
+  "tax withheld": -1,
+}
+
+
+def is_investment(file_path: str) -> bool:
+  """Check if file is an RBC Direct Investing statement by reading PDF content"""
+  try:
+    pdf_text = read_pdf(file_path)[:3000]
+    return "rbc direct investing" in pdf_text.lower()
+  except Exception:
+    return False
+
+
+def extract_account(pdf: str) -> dict:
+  """Account number and plan name, e.g. "RBC Direct Investing TFSA" """
+  result = {"number": None, "name": "RBC Direct Investing"}
+
+  if match := re.search(r"account (?:number|no\.?)[:\s]+([0-9][0-9-]*[0-9])", pdf, re.IGNORECASE):
+    result["number"] = match.group(1)
+
+  if match := re.search(rf"\b({PAT_PLAN})\b", pdf):
+    result["name"] = f"RBC Direct Investing {match.group(1)}"
+
+  return result
+
+
+def extract_period(pdf: str) -> tuple[Optional[datetime], Optional[datetime]]:
+  regex = rf"({PAT_DATE_LONG}) (?:to|-) ({PAT_DATE_LONG})"
+
+  if match := re.search(regex, pdf.replace("\xa0", " "), re.IGNORECASE):
+    end_year = match[8]
+    if not end_year:
+      return None, None
+
+    start = datetime.strptime(f"{match[2]} {match[3]} {match[4] or end_year}", "%B %d %Y")
+    end = datetime.strptime(f"{match[6]} {match[7]} {end_year}", "%B %d %Y")
+    return start, end
+
+  return None, None
+
+
+def extract_amount(pdf: str, label: str) -> Optional[float]:
+  regex = rf"{label}[^\n\d$(-]*\n?\s*({PAT_AMOUNT})"
+
+  if match := re.search(regex, pdf.replace("\xa0", " "), re.IGNORECASE):
+    return parse_amount(match[1])
+
+  return None
+
+
+def parse_amount(string: str) -> float:
+  negative = string.startswith("(") and string.endswith(")")
+  amount = parse_float(string.strip("()"))
+  return -amount if negative else amount
+
+
+def parse_investment_statement(pdf_path: str) -> dict:
+  """Extract statement period, opening/closing cash balances and the total
+  account value (cash plus holdings at market value) at the end of the period"""
+  pdf = read_pdf(pdf_path)
+  period_start, period_end = extract_period(pdf)
+
+  return {
+    "period_start": period_start,
+    "period_end": period_end,
+    "opening_balance": extract_amount(pdf, r"opening cash balance"),
+    "closing_balance": extract_amount(pdf, r"closing cash balance"),
+    "account_value": extract_amount(pdf, r"(?:total|closing) (?:account|portfolio) value"),
+  }
+
+
+def activity_sign(activity: str) -> Optional[int]:
+  activity = activity.lower()
+  for name, sign in CASH_ACTIVITIES.items():
+    if activity.startswith(name):
+      return sign
+
+  return None
+
+
+def parse_date(string: str, start_date: Optional[datetime]) -> Optional[datetime]:
+  month, day = string.split()
+  year = start_date.year if start_date else datetime.now().year
+
+  try:
+    date = datetime.strptime(f"{month[:3]} {day} {year}", "%b %d %Y")
+  except ValueError:
+    return None
+
+  # statements spanning a year end list January dates after December ones
+  if start_date and date.month < start_date.month:
+    date = date.replace(year=year + 1)
+
+  return date
+
+
+def parse_investment(
+  pdf_path: str,
+  categories: Dict[str, List[str]] = None,
+  excludes: List[str] = None,
+) -> List[Transaction]:
+  """Cash movements from the account activity section. Each row starts with a
+  short date, followed by the activity type, a description and the amounts;
+  the last amount on the row is the net cash amount."""
+  pdf = read_pdf(pdf_path).replace("\xa0", " ")
+  start_date, _ = extract_period(pdf)
+
+  section = re.split(r"account activity", pdf, maxsplit=1, flags=re.IGNORECASE)
+  if len(section) < 2:
+    return []
+
+  rows = []
+  row = None
+  for line in section[1].splitlines():
+    line = line.strip()
+    if not line:
+      continue
+
+    if re.match(rf"^{PAT_DATE_SHORT}$", line, re.IGNORECASE):
+      row = {"date": line, "lines": []}
+      rows.append(row)
+    elif re.match(r"^(closing|opening) cash balance", line, re.IGNORECASE):
+      row = None
+    elif row is not None:
+      row["lines"].append(line)
+
+  transactions = []
+  for row in rows:
+    if not row["lines"]:
+      continue
+
+    sign = activity_sign(row["lines"][0])
+    amounts = [line for line in row["lines"] if re.match(rf"^{PAT_AMOUNT}$", line)]
+    date = parse_date(row["date"], start_date)
+    if sign is None or not amounts or not date:
+      continue
+
+    amount = abs(parse_amount(amounts[-1])) * sign
+    if amount == 0:
+      continue
+
+    # quantities and prices are left out of the description
+    text = [line for line in row["lines"] if not re.match(r"^\(?-?\$?[\d,.]+\)?$", line)]
+    description = " ".join(text)
+    tx: Transaction = {
+      "date": date,
+      "posting_date": date,
+      "amount": amount,
+      "description": description,
+      "method": "investment",
+      "category": match_category(description, categories),
+    }
+
+    if not should_exclude(description, excludes):
+      transactions.append(tx)
+
+  return transactions
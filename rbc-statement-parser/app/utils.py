@@ -11,7 +11,16 @@ def parse_float(string: str):
   return float(string.replace("$", "").replace(",", ""))
 
 
-def read_pdf(pdf_path: str, html: bool = False) -> str:
+class EncryptedPDFError(Exception):
+  pass
+
+
+def pdf_passwords() -> list:
+  """Passwords to try on encrypted PDFs, one per line in $PDF_PASSWORDS"""
+  return [p for p in os.environ.get("PDF_PASSWORDS", "").split("\n") if p]
+
+
+def open_pdf(pdf_path: str) -> fitz.Document:
   if not os.path.exists(pdf_path):
     raise FileNotFoundError(f"File {pdf_path} not found")
 
@@ -19,6 +28,18 @@ def read_pdf(pdf_path: str, html: bool = False) -> str:
     raise TypeError(f"File {pdf_path} is not a recognized PDF")
 
   document = fitz.open(pdf_path)
+  if document.needs_pass:
+    passwords = pdf_passwords()
+    if not any(document.authenticate(p) for p in passwords):
+      if passwords:
+        raise EncryptedPDFError(f"PDF is password protected and none of the {len(passwords)} password(s) opened it")
+      raise EncryptedPDFError("PDF is password protected and no password was given")
+
+  return document
+
+
+def read_pdf(pdf_path: str, html: bool = False) -> str:
+  document = open_pdf(pdf_path)
   string = ""
 
   for page_num in range(len(document)):
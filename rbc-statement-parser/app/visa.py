@@ -33,6 +33,42 @@ def extract_start_date(pdf: str) -> Optional[datetime]:
   return None
 
 
+def extract_end_date(pdf: str) -> Optional[datetime]:
+  regex = rf"statement from ({PAT_DATE_LONG}) to ({PAT_DATE_LONG})"
+
+  if match := re.search(regex, pdf.replace("\xa0", " "), re.IGNORECASE):
+    end_month = match[6]
+    end_day = match[7]
+    end_year = match[8]
+
+    if end_year:
+      return parse_date(f"{end_month} {end_day} {end_year}")
+
+  return None
+
+
+def extract_balance(pdf: str, label: str) -> Optional[float]:
+  regex = rf"{label}\s*({PAT_AMOUNT})"
+
+  if match := re.search(regex, pdf.replace("\xa0", " "), re.IGNORECASE):
+    return parse_float(match[1])
+
+  return None
+
+
+def parse_visa_statement(pdf_path: str) -> dict:
+  """Extract statement period and previous/new balances. Balances are amounts
+  owed, so a credit balance comes out negative."""
+  pdf = read_pdf(pdf_path)
+
+  return {
+    "period_start": extract_start_date(pdf),
+    "period_end": extract_end_date(pdf),
+    "opening_balance": extract_balance(pdf, r"previous (?:statement|account) balance"),
+    "closing_balance": extract_balance(pdf, r"new balance"),
+  }
+
+
 def parse_date(string: str) -> datetime:
   return datetime.strptime(string, "%b %d %Y")
 
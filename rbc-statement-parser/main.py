@@ -3,10 +3,11 @@ import json
 import os
 import sys
 
-from app.chequing import is_chequing, parse_chequing
+from app.chequing import is_chequing, parse_chequing, parse_chequing_statement
 from app.entities import Config
-from app.utils import format_transaction, write_file
-from app.visa import is_visa, parse_visa
+from app.investment import extract_account, is_investment, parse_investment, parse_investment_statement
+from app.utils import EncryptedPDFError, format_transaction, open_pdf, write_file
+from app.visa import is_visa, parse_visa, parse_visa_statement
 
 
 def parse_config(path: str) -> Config:
@@ -34,13 +35,13 @@ def parse_files(path: str) -> list:
 
 def parse_args() -> tuple[list, dict, str, str]:
   parser = argparse.ArgumentParser(
-    description="A script that parses RBC chequing and VISA statements in PDF format and extracts transactions"
+    description="A script that parses RBC chequing, VISA and Direct Investing statements in PDF format and extracts transactions"
   )
 
   parser.add_argument("path", help="Path or to PDF or directory of PDFs")
   parser.add_argument("--config", "-c", help="Path to config file", default=".rc")
   parser.add_argument("--out", "-o", help="Path to output file")
-  parser.add_argument("--format", "-f", help="Output format", choices=["text", "json"], default="text")
+  parser.add_argument("--format", "-f", help="Output format", choices=["text", "json", "ndjson"], default="text")
 
   args = parser.parse_args()
   config = parse_config(args.config)
@@ -53,6 +54,21 @@ def parse_args() -> tuple[list, dict, str, str]:
   return (files, config, args.out, args.format)
 
 
+# Wording RBC uses for US dollar accounts and cards, e.g. "RBC U.S. Dollar
+# Visa Gold" or "RBC U.S. High Interest eSavings". A bare "USD" is not enough
+# since CAD Visa statements show it next to foreign purchases.
+PAT_USD = r"\bu\.?\s?s\.? (?:dollar|personal|high interest)|\(us\$\)|in u\.?s\.? (?:dollars|funds)"
+
+
+def detect_currency(pdf_text: str) -> str:
+  """Statement currency from the header, CAD unless it says US dollars"""
+  import re
+
+  if re.search(PAT_USD, pdf_text, re.IGNORECASE):
+    return "USD"
+  return "CAD"
+
+
 def extract_account_from_pdf(file_path: str) -> dict:
   """Auto-detect account type, number, and name from PDF content"""
   from app.utils import read_pdf
@@ -61,15 +77,21 @@ def extract_account_from_pdf(file_path: str) -> dict:
   result = {
     "type": None,
     "number": None,
-    "name": None
+    "name": None,
+    "currency": "CAD",
   }
 
   try:
     # Read first page of PDF to check header
     pdf_text = read_pdf(file_path)[:3000]  # First 3000 chars should contain all header info
-
-    # Detect account type
-    if "personal savings account statement" in pdf_text.lower():
+    result["currency"] = detect_currency(pdf_text)
+
+    # Detect account type. Direct Investing statements come first since they
+    # can mention savings and credit in their disclosures.
+    if "rbc direct investing" in pdf_text.lower():
+      result["type"] = "investment"
+      return {**result, **extract_account(pdf_text)}
+    elif "personal savings account statement" in pdf_text.lower():
       result["type"] = "savings"
     elif "personal banking account statement" in pdf_text.lower():
       result["type"] = "chequing"
@@ -113,6 +135,7 @@ def extract_account_info(file_path: str) -> dict:
   account_type = pdf_info["type"]
   account_number = pdf_info["number"]
   account_name = pdf_info["name"]
+  currency = pdf_info["currency"]
 
   # If auto-detection fails, fall back to filename
   if not account_type:
@@ -156,14 +179,19 @@ def extract_account_info(file_path: str) -> dict:
   return {
     "account_number": account_number,
     "account_type": account_type,
-    "account_name": account_name
+    "account_name": account_name,
+    "currency": currency,
   }
 
 
 def parse_pdf(file_path: str, categories: dict, excludes: list) -> list:
+  # fail loudly on encrypted files, the detectors below would just skip them
+  open_pdf(file_path).close()
   account_info = extract_account_info(file_path)
   
-  if is_chequing(file_path):
+  if is_investment(file_path):
+    transactions = parse_investment(file_path, categories, excludes)
+  elif is_chequing(file_path):
     transactions = parse_chequing(file_path, categories, excludes)
   elif is_visa(file_path):
     transactions = parse_visa(file_path, categories, excludes)
@@ -175,13 +203,97 @@ def parse_pdf(file_path: str, categories: dict, excludes: list) -> list:
     tx["account_number"] = account_info["account_number"]
     tx["account_type"] = account_info["account_type"]
     tx["account_name"] = account_info["account_name"]
+    tx["currency"] = account_info["currency"]
     tx["source_file"] = file_path
   
   return transactions
 
 
+def parse_statement(file_path: str) -> dict:
+  """Statement-level metadata: account, period and balances"""
+  account_info = extract_account_info(file_path)
+
+  statement = {}
+  try:
+    if is_investment(file_path):
+      statement = parse_investment_statement(file_path)
+    elif is_chequing(file_path):
+      statement = parse_chequing_statement(file_path)
+    elif is_visa(file_path):
+      statement = parse_visa_statement(file_path)
+  except Exception as e:
+    # the transactions may still be fine, so report it without failing the file
+    return {**account_info, "statement_error": str(e)}
+
+  for key in ("period_start", "period_end"):
+    if statement.get(key):
+      statement[key] = statement[key].isoformat()
+
+  return {**account_info, **statement}
+
+
+# Version of the ndjson protocol spoken to the Go side. Bump it when a record
+# changes shape.
+PROTOCOL_VERSION = 1
+
+
+def serialize_transaction(tx: dict) -> dict:
+  json_tx = dict(tx)
+  json_tx["date"] = tx["date"].isoformat()
+  json_tx["posting_date"] = tx["posting_date"].isoformat()
+  return json_tx
+
+
+def emit(record: dict):
+  print(json.dumps(record), flush=True)
+
+
+def stream_ndjson(files: list, config: Config):
+  """One record per line: a header, every transaction, a status per file and a
+  final summary. A file that raises is reported and the rest still parse."""
+  emit({"type": "header", "version": PROTOCOL_VERSION})
+
+  processed = failed = total = 0
+  for file in files:
+    try:
+      file_transactions = parse_pdf(file, config.get("categories"), config.get("excludes"))
+      statement = parse_statement(file)
+      # serialize before emitting, so a bad line fails only its own file
+      lines = [
+        json.dumps({"type": "transaction", **serialize_transaction(tx)})
+        for tx in sorted(file_transactions, key=lambda tx: tx["date"])
+      ]
+      status = "ok" if file_transactions else "skipped"
+      lines.append(json.dumps({"type": "file", "file": file, "status": status, "transaction_count": len(file_transactions), **statement}))
+    except EncryptedPDFError as e:
+      failed += 1
+      emit({"type": "file", "file": file, "status": "error", "error": str(e), "encrypted": True, "transaction_count": 0})
+      continue
+    except Exception as e:
+      failed += 1
+      emit({"type": "file", "file": file, "status": "error", "error": str(e), "transaction_count": 0})
+      continue
+
+    for line in lines:
+      print(line, flush=True)
+    processed += 1 if file_transactions else 0
+    total += len(file_transactions)
+
+  emit({
+    "type": "summary",
+    "total_files": len(files),
+    "processed_files": processed,
+    "failed_files": failed,
+    "total_transactions": total,
+  })
+
+
 def main():
   files, config, out_file, output_format = parse_args()
+
+  if output_format == "ndjson":
+    stream_ndjson(files, config)
+    return
   
   # Parse transactions and track file processing
   file_results = []
@@ -192,7 +304,8 @@ def main():
     file_results.append({
       "file": file,
       "transaction_count": len(file_transactions),
-      "processed": len(file_transactions) > 0
+      "processed": len(file_transactions) > 0,
+      **(parse_statement(file) if output_format == "json" else {}),
     })
     transactions.extend(file_transactions)
   
@@ -201,12 +314,7 @@ def main():
   
   if output_format == "json":
     # Convert datetime objects to strings for JSON serialization
-    json_transactions = []
-    for tx in transactions:
-      json_tx = dict(tx)
-      json_tx["date"] = tx["date"].isoformat()
-      json_tx["posting_date"] = tx["posting_date"].isoformat()
-      json_transactions.append(json_tx)
+    json_transactions = [serialize_transaction(tx) for tx in transactions]
     
     # Include file processing metadata
     result = {